@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/control"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"github.com/wombatlord/last-player-on-the-left/src/view"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// output holds the flag shared by the subcommands that print feed data
+type output struct {
+	Json bool `arg:"--json" help:"print JSON instead of a table"`
+}
+
+type feedsArgs struct {
+	output
+}
+
+type episodesArgs struct {
+	output
+	Alias string `arg:"positional,required" help:"the alias of the subscription"`
+	Limit int    `arg:"-n, --limit" help:"show at most this many of the latest episodes"`
+}
+
+type episodeArgs struct {
+	output
+	Alias string `arg:"positional,required" help:"the alias of the subscription"`
+	Index int    `arg:"positional,required" help:"the episode, by the index listed by episodes"`
+}
+
+type refreshArgs struct {
+	output
+}
+
+// episodeRow is an episode along with its index in the feed
+type episodeRow struct {
+	Index int `json:"index"`
+	*clients.Item
+}
+
+// refreshRow is the outcome of fetching a single subscription
+type refreshRow struct {
+	app.Subscription
+	Title    string `json:"title,omitempty"`
+	Episodes int    `json:"episodes"`
+	Latest   string `json:"latest,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// feeds lists the subscriptions
+func feeds(argv []string) error {
+	var args feedsArgs
+	if err := parseSubcommand("feeds", &args, argv); err != nil {
+		return err
+	}
+
+	if args.Json {
+		return printJSON(conf.Config.Subs)
+	}
+	table := newTable("ALIAS", "URL")
+	for _, sub := range conf.Config.Subs {
+		table.row(sub.Alias, sub.Url)
+	}
+	return table.Flush()
+}
+
+// episodes lists the episodes of a subscription, newest first. Each is listed with its
+// index in the feed, which show and play take, and those without a date come last.
+func episodes(argv []string) error {
+	var args episodesArgs
+	if err := parseSubcommand("episodes", &args, argv); err != nil {
+		return err
+	}
+
+	logfile, _, err := headless()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logfile.Close() }()
+
+	channel, err := loadChannel(args.Alias)
+	if err != nil {
+		return err
+	}
+	rows := make([]episodeRow, len(channel.Item))
+	for i := range channel.Item {
+		rows[i] = episodeRow{Index: i, Item: &channel.Item[i]}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i].Published, rows[j].Published
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.After(b)
+	})
+	if args.Limit > 0 && args.Limit < len(rows) {
+		rows = rows[:args.Limit]
+	}
+
+	if args.Json {
+		return printJSON(rows)
+	}
+	table := newTable("#", "PUBLISHED", "TITLE")
+	for _, row := range rows {
+		table.row(fmt.Sprint(row.Index), formatDate(row.Item), row.Title)
+	}
+	return table.Flush()
+}
+
+// formatDate returns the date the episode was published, or a dash if it has none
+func formatDate(episode *clients.Item) string {
+	if episode.Published.IsZero() {
+		return "-"
+	}
+	return episode.Published.Local().Format("2006-01-02")
+}
+
+// show prints the details of a single episode
+func show(argv []string) error {
+	var args episodeArgs
+	if err := parseSubcommand("show", &args, argv); err != nil {
+		return err
+	}
+
+	logfile, _, err := headless()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logfile.Close() }()
+
+	episode, err := loadEpisode(args.Alias, args.Index)
+	if err != nil {
+		return err
+	}
+
+	if args.Json {
+		return printJSON(episodeRow{Index: args.Index, Item: episode})
+	}
+	table := newTable()
+	table.row("Title:", episode.Title)
+	table.row("Published:", formatDate(episode))
+	table.row("Author:", episode.Author)
+	table.row("Link:", episode.Link)
+	table.row("Audio:", episode.Enclosure.Url)
+	table.row("Size:", fmt.Sprintf("%d bytes", episode.Enclosure.Length))
+	if err = table.Flush(); err != nil {
+		return err
+	}
+	notes, links := view.ShowNotesText(episode.ShowNotes())
+	if notes != "" {
+		fmt.Printf("\n%s\n", notes)
+	}
+	if len(links) > 0 {
+		fmt.Println("\nLinks")
+		for i, link := range links {
+			fmt.Printf("[%d] %s\n", i+1, link)
+		}
+	}
+	return nil
+}
+
+// play plays a single episode through the configured output without the terminal UI,
+// returning once it has finished, along with anything queued after it. The player can be
+// controlled with ctl while it plays.
+func play(argv []string) error {
+	var args episodeArgs
+	if err := parseSubcommand("play", &args, argv); err != nil {
+		return err
+	}
+
+	logfile, getLogger, err := headless()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logfile.Close() }()
+
+	episode, err := loadEpisode(args.Alias, args.Index)
+	if err != nil {
+		return err
+	}
+	panel, err := headlessPanel(getLogger)
+	if err != nil {
+		return err
+	}
+
+	if server, err := control.Listen(control.SocketPath(), panel, getLogger("Control")); err != nil {
+		getLogger("Play").Printf("Remote control is unavailable: %v", err)
+	} else {
+		go server.Serve()
+		defer func() { _ = server.Close() }()
+	}
+
+	sub := domain.Subscribe(domain.PositionTickTopic, func(event domain.Event) {
+		tick := event.(domain.PositionTick)
+		if args.Json {
+			_ = json.NewEncoder(os.Stdout).Encode(tick)
+		} else {
+			fmt.Printf("\r%s / %s ", tick.Position.Round(time.Second), tick.Length.Round(time.Second))
+		}
+	})
+	defer domain.Unsubscribe(sub)
+
+	// Playback is over once a stream has drained with nothing queued after it, which may
+	// be well after the position passed the length estimated while it was downloading
+	finished := make(chan struct{})
+	var once sync.Once
+	end := domain.Subscribe(domain.EpisodeFinishedTopic, func(domain.Event) {
+		if len(panel.Queue()) == 0 {
+			once.Do(func() { close(finished) })
+		}
+	})
+	defer domain.Unsubscribe(end)
+
+	if !args.Json {
+		fmt.Println(episode.Title)
+	}
+	if err = panel.Play(episode); err != nil {
+		return err
+	}
+	panel.SpawnPublisher()
+
+	<-finished
+	if !args.Json {
+		fmt.Println()
+	}
+	return nil
+}
+
+// refresh fetches every subscription and reports how many episodes each has. An error
+// is returned if any of them could not be fetched.
+func refresh(argv []string) error {
+	var args refreshArgs
+	if err := parseSubcommand("refresh", &args, argv); err != nil {
+		return err
+	}
+
+	logfile, _, err := headless()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logfile.Close() }()
+
+	rows := make([]refreshRow, len(conf.Config.Subs))
+	failed := 0
+	for i, sub := range conf.Config.Subs {
+		rows[i].Subscription = sub
+		feed, err := clients.GetContent(sub.Url)
+		if err != nil {
+			rows[i].Error = err.Error()
+			failed++
+			continue
+		}
+		channel := feed.Channel[0]
+		rows[i].Title = channel.Title
+		rows[i].Episodes = len(channel.Item)
+		if len(channel.Item) > 0 {
+			rows[i].Latest = channel.Item[0].Title
+		}
+	}
+
+	if args.Json {
+		err = printJSON(rows)
+	} else {
+		table := newTable("ALIAS", "EPISODES", "LATEST")
+		for _, row := range rows {
+			if row.Error != "" {
+				table.row(row.Alias, "-", "error: "+row.Error)
+				continue
+			}
+			table.row(row.Alias, fmt.Sprint(row.Episodes), row.Latest)
+		}
+		err = table.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds could not be refreshed", failed, len(rows))
+	}
+	return nil
+}
+
+// loadChannel fetches the feed of the subscription with the alias, the loggers must have
+// been set up with headless first
+func loadChannel(alias string) (*clients.Channel, error) {
+	sub := conf.Config.GetByAlias(alias)
+	if sub.Alias == "" {
+		return nil, fmt.Errorf("no subscription with alias %s", alias)
+	}
+
+	feed, err := clients.GetContent(sub.Url)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", alias, err)
+	}
+	return &feed.Channel[0], nil
+}
+
+// loadEpisode fetches a single episode of the subscription with the alias
+func loadEpisode(alias string, index int) (*clients.Item, error) {
+	channel, err := loadChannel(alias)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(channel.Item) {
+		return nil, fmt.Errorf("no episode %d in %s, it has %d", index, alias, len(channel.Item))
+	}
+	return &channel.Item[index], nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// table writes tab aligned columns to stdout
+type table struct {
+	*tabwriter.Writer
+}
+
+// newTable starts a table, writing the header row if any columns are named
+func newTable(header ...string) table {
+	t := table{tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)}
+	if len(header) > 0 {
+		t.row(header...)
+	}
+	return t
+}
+
+// row writes a single row of the table
+func (t table) row(columns ...string) {
+	_, _ = fmt.Fprintln(t, strings.Join(columns, "\t"))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"github.com/alexflint/go-arg"
+	"github.com/wombatlord/last-player-on-the-left/src/control"
+	"os"
+)
+
+type ctlArgs struct {
+	Command string   `arg:"positional,required" help:"one of play, pause, toggle, seek, skip, volume, speed, enqueue or state"`
+	Args    []string `arg:"positional" help:"arguments to the command, e.g. seek 12:30, seek +30, volume 0.5, enqueue URL. Put -- before a negative seek"`
+	Socket  string   `arg:"--socket" help:"path to the control socket of the running player"`
+}
+
+// ctl sends a single command to the running player over its control socket and prints
+// the JSON reply
+func ctl(argv []string) error {
+	var args ctlArgs
+	if err := parseSubcommand("ctl", &args, argv); err != nil {
+		return err
+	}
+
+	socket := args.Socket
+	if socket == "" {
+		socket = control.SocketPath()
+	}
+
+	resp, err := control.Send(socket, control.Request{Command: args.Command, Args: args.Args})
+	if err != nil {
+		return err
+	}
+
+	if err = printJSON(resp); err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// parseSubcommand parses the arguments following a subcommand name into dest, printing
+// help or usage and exiting as arg.MustParse would
+func parseSubcommand(name string, dest interface{}, argv []string) error {
+	parser, err := arg.NewParser(arg.Config{Program: os.Args[0] + " " + name}, dest)
+	if err != nil {
+		return err
+	}
+
+	switch err = parser.Parse(argv); {
+	case err == arg.ErrHelp:
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	case err != nil:
+		parser.Fail(err.Error())
+	}
+	return nil
+}
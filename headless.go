@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+)
+
+// headless opens the log file and sets up the loggers of the clients package, as
+// view.Build does for the terminal UI. The caller is responsible for closing the log file.
+func headless() (*os.File, func(prefix string) *log.Logger, error) {
+	logfile, err := conf.Config.OpenLog()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpClient, err := conf.Config.HTTP.Client()
+	if err != nil {
+		_ = logfile.Close()
+		return nil, nil, fmt.Errorf("http: %v", err)
+	}
+	clients.SetHTTPClient(httpClient)
+
+	getLogger := func(prefix string) *log.Logger {
+		return app.NewLogger(logfile, prefix)
+	}
+	clients.InitLoggers(getLogger)
+	clients.SetProgressFunc(func(id, url string, complete, total int64) {
+		domain.Publish(domain.DownloadProgress{Episode: id, Url: url, Complete: complete, Total: total})
+	})
+	logger := getLogger("Feeds")
+	clients.SetMoveFunc(func(from, to string) {
+		aliases, withheld, err := conf.MoveFeed(from, to)
+		if err != nil {
+			logger.Printf("Could not save the move of %s to %s: %v", from, to, err)
+		} else if len(aliases) > 0 {
+			logger.Printf("Moved %s to %s", strings.Join(aliases, ", "), to)
+		}
+		if len(withheld) > 0 {
+			logger.Printf("Not sending the credentials of %s to %s, it is on another host", strings.Join(withheld, ", "), to)
+		}
+		domain.Publish(domain.FeedMoved{From: from, To: to})
+	})
+	if err := conf.Config.Authorise(); err != nil {
+		logger.Printf("%v, requesting those feeds without", err)
+	}
+
+	return logfile, getLogger, nil
+}
+
+// headlessPanel sets up the AudioPanel with the output and cache from the config
+func headlessPanel(getLogger func(prefix string) *log.Logger) (*audiopanel.AudioPanel, error) {
+	if err := os.MkdirAll(conf.Config.Cache, fs.ModeDir+fs.FileMode(0774)); err != nil {
+		return nil, err
+	}
+	if err := clients.LoadResolved(conf.Config.Cache); err != nil {
+		getLogger("AudioPanel").Printf("Could not read the resolved urls: %v", err)
+	}
+	output, err := audiopanel.NewOutput(conf.Config.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	return audiopanel.
+		FetchAudioPanel().
+		AttachLogger(getLogger("AudioPanel")).
+		SetCachePath(conf.Config.Cache).
+		AttachOutput(output), nil
+}
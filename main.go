@@ -19,11 +19,30 @@ var (
 	err  error
 )
 
+// subcommands are dispatched on the first argument, ahead of the usual argument parsing
+var subcommands = map[string]func(argv []string) error{
+	"ctl":      ctl,
+	"serve":    serve,
+	"feeds":    feeds,
+	"episodes": episodes,
+	"show":     show,
+	"play":     play,
+	"refresh":  refresh,
+}
+
 func main() {
 	// Load the config file
 	conf, err = app.LoadConfig()
 	fatal(err)
 
+	// Hand off to a subcommand if one was named
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			fatal(subcommand(os.Args[2:]))
+			return
+		}
+	}
+
 	// Create the logger
 	logfile, err := os.Open(conf.Config.Logs)
 	fatal(err)
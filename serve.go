@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/server"
+	"net/http"
+	"time"
+)
+
+type serveArgs struct {
+	Addr  string `arg:"--addr" default:"127.0.0.1:8080" help:"the address to serve the HTTP API on"`
+	Token string `arg:"--token,env:LAST_PLAYER_TOKEN" help:"a bearer token required with every request, needed to serve beyond localhost"`
+}
+
+// serve runs the player without the terminal UI, exposing it over the HTTP/JSON API
+// until the process is killed
+func serve(argv []string) error {
+	var args serveArgs
+	if err := parseSubcommand("serve", &args, argv); err != nil {
+		return err
+	}
+	if args.Token == "" && !server.IsLoopback(args.Addr) {
+		return fmt.Errorf("serving on %s needs a --token, or LAST_PLAYER_TOKEN, as anyone who can reach it can control the player", args.Addr)
+	}
+
+	logfile, getLogger, err := headless()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logfile.Close() }()
+
+	panel, err := headlessPanel(getLogger)
+	if err != nil {
+		return err
+	}
+	panel.SpawnPublisher()
+
+	logger := getLogger("Server")
+	logger.Printf("Serving on %s", args.Addr)
+	// There is no write timeout, as /api/events streams for as long as it is open
+	httpServer := &http.Server{
+		Addr:              args.Addr,
+		Handler:           server.New(conf, panel, logger).RequireToken(args.Token),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ErrorLog:          logger,
+	}
+	return httpServer.ListenAndServe()
+}
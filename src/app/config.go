@@ -1,25 +1,210 @@
 package app
 
 import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
 	"gopkg.in/yaml.v2"
+	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Subscription represents a single alias <-> url pair. These are the items that show up
-// in the feeds menu
+// in the feeds menu. Auth holds the credentials of a private feed, which are never shown
+// over the api.
 type Subscription struct {
-	Alias string `yaml:"alias"`
-	Url   string `yaml:"url"`
+	Alias string `yaml:"alias" json:"alias"`
+	Url   string `yaml:"url" json:"url"`
+	Auth  *Auth  `yaml:"auth,omitempty" json:"-"`
+}
+
+// Secret is a password, token or header value. It may be given in the config itself, or
+// be read from an environment variable or the first line of a file so that the config
+// can be shared without it, e.g. `token: {env: PATREON_TOKEN}`. In the config it may
+// also be given as just the value, e.g. `password: hunter2`
+type Secret struct {
+	Value string `yaml:"value,omitempty"`
+	Env   string `yaml:"env,omitempty"`
+	File  string `yaml:"file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting a value or a mapping
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*s = Secret{Value: value}
+		return nil
+	}
+	type plain Secret
+	return unmarshal((*plain)(s))
+}
+
+// MarshalYAML implements yaml.Marshaler, writing a secret with only a value as the value
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s.Env == "" && s.File == "" {
+		return s.Value, nil
+	}
+	type plain Secret
+	return plain(s), nil
+}
+
+// IsZero reports whether no secret was given, so that it is left out of the config
+func (s Secret) IsZero() bool {
+	return s == Secret{}
+}
+
+// Reveal returns the secret, reading it from its environment variable or file if it
+// names one
+func (s Secret) Reveal() (string, error) {
+	switch {
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return value, nil
+	case s.File != "":
+		content, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(strings.SplitN(string(content), "\n", 2)[0]), nil
+	}
+	return s.Value, nil
+}
+
+// Auth is how a private feed is authenticated, with basic auth, a bearer token or any
+// other headers the host asks for. Feeds that put a token in their url need no Auth.
+// Host is the host the credentials are for. Left empty it is the host of the url of the
+// subscription, and it is filled in when the feed moves so that the credentials are
+// never sent to the host a feed moves to.
+type Auth struct {
+	Host     string            `yaml:"host,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password Secret            `yaml:"password,omitempty"`
+	Token    Secret            `yaml:"token,omitempty"`
+	Headers  map[string]Secret `yaml:"headers,omitempty"`
+}
+
+// Credentials reveals the secrets of the auth, returning the credentials to send with
+// the requests for the feed and its enclosures
+func (a *Auth) Credentials() (*clients.Credentials, error) {
+	password, err := a.Password.Reveal()
+	if err != nil {
+		return nil, fmt.Errorf("password: %v", err)
+	}
+	token, err := a.Token.Reveal()
+	if err != nil {
+		return nil, fmt.Errorf("token: %v", err)
+	}
+	headers := make(map[string]string, len(a.Headers))
+	for name, secret := range a.Headers {
+		if headers[name], err = secret.Reveal(); err != nil {
+			return nil, fmt.Errorf("header %s: %v", name, err)
+		}
+	}
+	return &clients.Credentials{Username: a.Username, Password: password, Token: token, Headers: headers}, nil
+}
+
+// isFor reports whether the credentials are for the host of the feed at feedUrl
+func (a *Auth) isFor(feedUrl string) bool {
+	return a.Host == "" || strings.EqualFold(a.Host, urlHost(feedUrl))
+}
+
+// urlHost returns the host of the url, or an empty string if it cannot be parsed
+func urlHost(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// Output selects the sink that audio is played through. Backend is one of speaker, null
+// or wav. Path is the file written by the wav backend and Speed scales the rate that the
+// null and wav backends consume audio at, 0 consumes it as fast as possible.
+type Output struct {
+	Backend string  `yaml:"backend"`
+	Path    string  `yaml:"path,omitempty"`
+	Speed   float64 `yaml:"speed,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, refusing a backend of an unquoted null,
+// which YAML reads as no backend at all and so would play through the speaker
+func (o *Output) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fields map[string]interface{}
+	if err := unmarshal(&fields); err == nil {
+		if backend, ok := fields["backend"]; ok && backend == nil {
+			return fmt.Errorf(`output: backend is empty, write "null" in quotes to use the null backend`)
+		}
+	}
+	type plain Output
+	return unmarshal((*plain)(o))
+}
+
+// KeySpecs are the keys bound to an action. In the config they may be given as a
+// single spec or a list of them, e.g. `play_pause: p` or `play_pause: [p, space]`
+type KeySpecs []string
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting a single spec or a list
+func (k *KeySpecs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var spec string
+	if err := unmarshal(&spec); err == nil {
+		*k = KeySpecs{spec}
+		return nil
+	}
+	var specs []string
+	if err := unmarshal(&specs); err != nil {
+		return err
+	}
+	*k = specs
+	return nil
+}
+
+// Theme selects one of the built in themes by Name and overrides any of its colors.
+// Colors are given as names, e.g. navy or darkgray, or as hex values, e.g. #1e90ff.
+// In the config it may also be given as just the name, e.g. `theme: light`
+type Theme struct {
+	Name          string `yaml:"name,omitempty"`
+	Background    string `yaml:"background,omitempty"`
+	Text          string `yaml:"text,omitempty"`
+	Secondary     string `yaml:"secondary,omitempty"`
+	Border        string `yaml:"border,omitempty"`
+	Title         string `yaml:"title,omitempty"`
+	Selection     string `yaml:"selection,omitempty"`
+	SelectedText  string `yaml:"selected_text,omitempty"`
+	Played        string `yaml:"played,omitempty"`
+	Unplayed      string `yaml:"unplayed,omitempty"`
+	Progress      string `yaml:"progress,omitempty"`
+	ProgressTrack string `yaml:"progress_track,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting a theme name or a mapping
+func (t *Theme) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*t = Theme{Name: name}
+		return nil
+	}
+	type plain Theme
+	return unmarshal((*plain)(t))
 }
 
 // Config represents all the configuration contained in a config file. It
 // specifies the config schema.
 type Config struct {
-	Subs  []Subscription `yaml:"subs"`
-	Logs  string         `yaml:"logs"`
-	Cache string         `yaml:"cache"`
+	Subs   []Subscription      `yaml:"subs"`
+	Logs   string              `yaml:"logs"`
+	Cache  string              `yaml:"cache"`
+	Output Output              `yaml:"output"`
+	Keymap string              `yaml:"keymap,omitempty"`
+	Keys   map[string]KeySpecs `yaml:"keys,omitempty"`
+	Theme  Theme               `yaml:"theme,omitempty"`
+	Layout string              `yaml:"layout,omitempty"`
+	HTTP   HTTP                `yaml:"http,omitempty"`
 }
 
 // GetByAlias returns the Subscription associated to the passed alias
@@ -49,6 +234,9 @@ var DefaultConfig = ConfigFile{
 		Subs:  []Subscription{},
 		Logs:  "logs/log.txt",
 		Cache: "cache",
+		Output: Output{
+			Backend: "speaker",
+		},
 	},
 }
 
@@ -61,12 +249,81 @@ func (s *ConfigFile) Include(alias string, url string) error {
 	return nil
 }
 
+// Exclude removes the subscription with the alias from the config and saves it
+func (s *ConfigFile) Exclude(alias string) error {
+	for i, sub := range s.Config.Subs {
+		if sub.Alias == alias {
+			s.Config.Subs = append(s.Config.Subs[:i], s.Config.Subs[i+1:]...)
+			return s.Save()
+		}
+	}
+	return fmt.Errorf("no subscription with alias %s", alias)
+}
+
+// MoveFeed points every subscription to the feed at from to the feed at to instead and
+// saves the config, returning the aliases of the subscriptions that were moved. The
+// credentials of private feeds stay with the host they were configured for, so the
+// aliases of those moved to another host are returned as withheld too.
+func (s *ConfigFile) MoveFeed(from string, to string) (moved []string, withheld []string, err error) {
+	for i, sub := range s.Config.Subs {
+		if sub.Url != from {
+			continue
+		}
+		s.Config.Subs[i].Url = to
+		moved = append(moved, sub.Alias)
+		if sub.Auth == nil {
+			continue
+		}
+		if sub.Auth.Host == "" {
+			sub.Auth.Host = urlHost(from)
+		}
+		if !sub.Auth.isFor(to) {
+			withheld = append(withheld, sub.Alias)
+		}
+	}
+	if len(moved) == 0 {
+		return nil, nil, nil
+	}
+	return moved, withheld, s.Save()
+}
+
+// Authorise registers the credentials of every subscription to a private feed with the
+// clients package, returning an error naming the subscriptions whose secrets could not
+// be read or whose feed is no longer on the host they are for. Those feeds are
+// requested without credentials.
+func (c Config) Authorise() error {
+	var failed []string
+	for _, sub := range c.Subs {
+		if sub.Auth == nil {
+			continue
+		}
+		if !sub.Auth.isFor(sub.Url) {
+			failed = append(failed, fmt.Sprintf("%s (they are for %s, not %s)", sub.Alias, sub.Auth.Host, urlHost(sub.Url)))
+			continue
+		}
+		credentials, err := sub.Auth.Credentials()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", sub.Alias, err))
+			continue
+		}
+		clients.Authorise(sub.Url, credentials)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not use the credentials of %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
 // Save updates the config file that the config was loaded from with any changes
 func (s *ConfigFile) Save() error {
 	content, err := yaml.Marshal(s.Config)
 	if err != nil {
 		return err
 	}
+	err = os.MkdirAll(filepath.Dir(s.Path), 0755)
+	if err != nil {
+		return err
+	}
 	err = os.WriteFile(s.Path, content, 0644)
 	if err != nil {
 		return err
@@ -87,12 +344,12 @@ func LoadConfig() (*ConfigFile, error) {
 		LoadedConfig = DefaultConfig.Config
 		err = DefaultConfig.Save()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		return &DefaultConfig, nil
 	}
 	conf.Path = path
-	err = yaml.Unmarshal(fileContent, &confVals)
+	confVals, err = parseConfig(fileContent)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +359,37 @@ func LoadConfig() (*ConfigFile, error) {
 	return &conf, nil
 }
 
+// ReadConfig reads the config at path without making it the loaded config, so that
+// changes made to the file while the application is running can be picked up
+func ReadConfig(path string) (Config, error) {
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return parseConfig(fileContent)
+}
+
+// parseConfig unmarshals the content of a config file
+func parseConfig(content []byte) (Config, error) {
+	var config Config
+	err := yaml.Unmarshal(content, &config)
+	return config, err
+}
+
+// OpenLog opens the configured log file for appending, creating it if it does not exist
+func (c Config) OpenLog() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(c.Logs), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(c.Logs, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// NewLogger returns a log.Logger writing to w with the prefix formatted as it is across
+// the application
+func NewLogger(w io.Writer, prefix string) *log.Logger {
+	return log.New(w, "[ "+prefix+" ]: ", 0)
+}
+
 // GetPath returns the config path as specified in env variable LAST_CONFIG_PATH_ON_THE_LEFT
 // If empty the fallback is ~/.config/LastPlayer/config.yaml
 func GetPath() string {
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputBackendNull(t *testing.T) {
+	config, err := parseConfig([]byte("output:\n  backend: \"null\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Output.Backend != "null" {
+		t.Errorf("a quoted null gave the backend %q", config.Output.Backend)
+	}
+
+	if _, err := parseConfig([]byte("output:\n  backend: null\n")); err == nil {
+		t.Error("an unquoted null was accepted, which would play through the speaker")
+	}
+	if _, err := parseConfig([]byte("output:\n  backend: ~\n")); err == nil {
+		t.Error("a backend of ~ was accepted, which would play through the speaker")
+	}
+}
+
+func TestMoveFeedKeepsCredentialsOnTheirHost(t *testing.T) {
+	file := &ConfigFile{Path: filepath.Join(t.TempDir(), "config.yaml"), Config: Config{Subs: []Subscription{
+		{Alias: "Premium", Url: "https://example.com/feed.xml", Auth: &Auth{Token: Secret{Value: "secret"}}},
+		{Alias: "Public", Url: "https://example.com/feed.xml"},
+	}}}
+
+	moved, withheld, err := file.MoveFeed("https://example.com/feed.xml", "https://example.com/new.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 2 || len(withheld) != 0 {
+		t.Errorf("a move on the same host moved %v and withheld %v", moved, withheld)
+	}
+	if err = file.Config.Authorise(); err != nil {
+		t.Errorf("the credentials were not used after a move on the same host: %v", err)
+	}
+
+	moved, withheld, err = file.MoveFeed("https://example.com/new.xml", "https://elsewhere.net/feed.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 2 || len(withheld) != 1 || withheld[0] != "Premium" {
+		t.Errorf("a move to another host moved %v and withheld %v", moved, withheld)
+	}
+
+	reloaded, err := ReadConfig(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := reloaded.Subs[0].Auth.Host; host != "example.com" {
+		t.Errorf("the credentials were saved for %q, expected example.com", host)
+	}
+	if err = reloaded.Authorise(); err == nil {
+		t.Error("the credentials were used for a feed on another host")
+	}
+}
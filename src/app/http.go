@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// The settings used for the requests made for feeds and audio where the config does not
+// give them
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultReadTimeout    = time.Minute
+	DefaultUserAgent      = "Last Player On The Left"
+	DefaultMaxRedirects   = 10
+)
+
+// HTTP configures the requests made for feeds and audio. ConnectTimeout bounds connecting
+// to a host, TLS handshake included, and ReadTimeout how long to wait for a response or
+// for the next of its data, a negative timeout waits forever. Proxy is an http, https,
+// socks5 or socks5h url, without one the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables are used. CABundle is a PEM file of certificates to trust as well as those of
+// the system.
+type HTTP struct {
+	ConnectTimeout time.Duration `yaml:"connect_timeout,omitempty"`
+	ReadTimeout    time.Duration `yaml:"read_timeout,omitempty"`
+	Proxy          string        `yaml:"proxy,omitempty"`
+	CABundle       string        `yaml:"ca_bundle,omitempty"`
+	UserAgent      string        `yaml:"user_agent,omitempty"`
+	MaxRedirects   int           `yaml:"max_redirects,omitempty"`
+}
+
+// Client returns a client made to the settings, filling in those not given with the
+// defaults. It is the client that every request for a feed or audio is made with, see
+// clients.SetHTTPClient.
+func (h HTTP) Client() (*http.Client, error) {
+	connectTimeout := orDefault(h.ConnectTimeout, DefaultConnectTimeout)
+	readTimeout := orDefault(h.ReadTimeout, DefaultReadTimeout)
+
+	proxy := http.ProxyFromEnvironment
+	if h.Proxy != "" {
+		proxyUrl, err := url.Parse(h.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		switch proxyUrl.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy: unsupported scheme %q, expected http, https, socks5 or socks5h", proxyUrl.Scheme)
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	tlsConfig := &tls.Config{}
+	if h.CABundle != "" {
+		pool, err := certPool(h.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca bundle: %v", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&deadlineDialer{dialer: dialer, timeout: readTimeout}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	agent := h.UserAgent
+	if agent == "" {
+		agent = DefaultUserAgent
+	}
+	maxRedirects := h.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	return &http.Client{
+		Transport: userAgentTransport{next: transport, agent: agent},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// orDefault returns the timeout if one was given, the default if not, or zero, which
+// waits forever, if the timeout is negative
+func orDefault(timeout, fallback time.Duration) time.Duration {
+	switch {
+	case timeout < 0:
+		return 0
+	case timeout == 0:
+		return fallback
+	}
+	return timeout
+}
+
+// certPool returns the certificates of the system with those in the PEM file added
+func certPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// userAgentTransport sets the User-Agent of every request
+type userAgentTransport struct {
+	next  http.RoundTripper
+	agent string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.agent)
+	return t.next.RoundTrip(req)
+}
+
+// deadlineDialer dials connections that give up on a read that waits longer than the
+// timeout, so that a stalled download fails rather than hanging. Unlike a timeout on the
+// whole request it leaves a long download that is still arriving alone.
+type deadlineDialer struct {
+	dialer  *net.Dialer
+	timeout time.Duration
+}
+
+// DialContext dials the address, as net.Dialer.DialContext
+func (d *deadlineDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil || d.timeout == 0 {
+		return conn, err
+	}
+	return &deadlineConn{Conn: conn, timeout: d.timeout}, nil
+}
+
+// deadlineConn moves its read deadline on before each read
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read implements io.Reader
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// State is what the player remembers between runs without it being configured. It is
+// kept in a file of its own beside the config, so that the config is only rewritten
+// when a setting is changed.
+type State struct {
+	Feeds map[string]FeedState `yaml:"feeds,omitempty"`
+	// Played holds the ids of the episodes that have been played, see clients.Item.ID
+	Played []string `yaml:"played,omitempty"`
+}
+
+// FeedState remembers how the episodes of a subscription were last listed
+type FeedState struct {
+	Sort    string `yaml:"sort,omitempty"`
+	Seasons bool   `yaml:"seasons,omitempty"`
+}
+
+// StateFile is the State along with the path of the file it is kept in
+type StateFile struct {
+	Path  string
+	State State
+	// mu guards State, which is changed as episodes start playing as well as from the UI
+	mu sync.Mutex
+	// played indexes State.Played, it is built when first needed
+	played map[string]bool
+}
+
+// GetStatePath returns the path of the state file, state.yaml in the directory of the
+// config
+func GetStatePath() string {
+	return filepath.Join(filepath.Dir(GetPath()), "state.yaml")
+}
+
+// LoadState reads the state file at path. There is nothing to remember before the file
+// is first saved, so a missing file gives an empty State.
+func LoadState(path string) (*StateFile, error) {
+	state := &StateFile{Path: path}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	return state, yaml.Unmarshal(content, &state.State)
+}
+
+// Feed returns the state of the subscription with the alias
+func (s *StateFile) Feed(alias string) FeedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.State.Feeds[alias]
+}
+
+// SetFeed remembers the state of the subscription with the alias, saving the state file
+// if it has changed
+func (s *StateFile) SetFeed(alias string, feed FeedState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State.Feeds[alias] == feed {
+		return nil
+	}
+	if s.State.Feeds == nil {
+		s.State.Feeds = map[string]FeedState{}
+	}
+	s.State.Feeds[alias] = feed
+	return s.save()
+}
+
+// Played reports whether the episode with the id has been played
+func (s *StateFile) Played(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index()[id]
+}
+
+// SetPlayed remembers that the episode with the id has been played, saving the state
+// file if it had not been already
+func (s *StateFile) SetPlayed(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index()[id] {
+		return nil
+	}
+	s.State.Played = append(s.State.Played, id)
+	s.played[id] = true
+	return s.save()
+}
+
+// index returns the index of the played episodes, the caller must hold s.mu
+func (s *StateFile) index() map[string]bool {
+	if s.played == nil {
+		s.played = make(map[string]bool, len(s.State.Played))
+		for _, id := range s.State.Played {
+			s.played[id] = true
+		}
+	}
+	return s.played
+}
+
+// Save writes the state to its file
+func (s *StateFile) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save writes the state to its file, the caller must hold s.mu
+func (s *StateFile) save() error {
+	content, err := yaml.Marshal(s.State)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, content, 0644)
+}
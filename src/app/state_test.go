@@ -0,0 +1,71 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateOnlySavesChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("a missing state file gave %v", err)
+	}
+
+	newest := FeedState{Sort: "newest", Seasons: true}
+	if err = state.SetFeed("LPOTL", newest); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := saved.ModTime().Add(-time.Hour)
+	if err = os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err = state.SetFeed("LPOTL", newest); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(past) {
+		t.Error("the state file was written without a change")
+	}
+
+	reloaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed := reloaded.Feed("LPOTL"); feed != newest {
+		t.Errorf("reloading the state gave %+v, expected %+v", feed, newest)
+	}
+}
+
+func TestStateRemembersPlayedEpisodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Played("guid-1") {
+		t.Error("an episode was played before anything was")
+	}
+	for _, id := range []string{"guid-1", "guid-2", "guid-1"} {
+		if err = state.SetPlayed(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Played("guid-1") || !reloaded.Played("guid-2") || reloaded.Played("guid-3") {
+		t.Errorf("reloading the state gave the played episodes %v", reloaded.State.Played)
+	}
+	if len(reloaded.State.Played) != 2 {
+		t.Errorf("an episode played twice was remembered as %v", reloaded.State.Played)
+	}
+}
@@ -4,53 +4,99 @@ import (
 	"fmt"
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
-	"github.com/faiface/beep/speaker"
 	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"sync"
 	"time"
 )
 
 var (
-	panel = &AudioPanel{}
+	panel = &AudioPanel{output: &SpeakerOutput{}, gain: 1, speed: 1}
 )
 
+// The range that the playback speed is clamped to, and the highest gain that the volume
+// may be raised to
+const (
+	MinSpeed  = 0.25
+	MaxSpeed  = 4.0
+	MaxVolume = 4.0
+)
+
+// ErrNothingPlaying is returned by the transport controls when there is no stream to control
+var ErrNothingPlaying = fmt.Errorf("nothing is playing")
+
 // PlayerState is a snapshot of the current player state
 type PlayerState struct {
-	Position time.Duration
-	Length   time.Duration
-	Playing  bool
-}
-
-type PlayerStateSubscriber interface {
-	OnUpdate()
+	Position time.Duration `json:"position"`
+	Length   time.Duration `json:"length"`
+	Playing  bool          `json:"playing"`
+	Volume   float64       `json:"volume"`
+	Speed    float64       `json:"speed"`
 }
 
 // AudioPanel contains properties for manipulating an audio stream & drawing info to the terminal. eg. volume / seeking & position
 type AudioPanel struct {
-	sampleRate  beep.SampleRate
-	streamer    beep.StreamSeekCloser
-	ctrl        *beep.Ctrl
-	resampler   *beep.Resampler
-	volume      *effects.Volume
-	subscribers []PlayerStateSubscriber
-	clock       *time.Ticker
-	Format      beep.Format
-	logger      *log.Logger
-	callback    func(func())
+	// mu guards the stream and the settings applied to it, which are changed from the UI
+	// as well as the external control interfaces. It is taken before the output lock,
+	// which guards whatever the output reads as it plays.
+	mu         sync.Mutex
+	sampleRate beep.SampleRate
+	streamer   beep.StreamSeekCloser
+	ctrl       *beep.Ctrl
+	resampler  *beep.Resampler
+	volume     *effects.Volume
+	clock      *time.Ticker
+	Format     beep.Format
+	logger     *log.Logger
+	output     Output
+	cachePath  string
+	gain       float64
+	speed      float64
+	playing    *clients.Item
+	queue      []*clients.Item
+	// queueLock guards playing and queue, which are changed from the UI as well as
+	// the external control interfaces
+	queueLock sync.Mutex
 }
 
+// PlayPause toggles between paused and playing
 func (ap *AudioPanel) PlayPause() {
+	ap.mu.Lock()
+	if ap.ctrl == nil {
+		ap.mu.Unlock()
+		return
+	}
+	ap.output.Lock()
+	paused := !ap.ctrl.Paused
+	ap.output.Unlock()
+	ap.mu.Unlock()
+	ap.SetPaused(paused)
+}
+
+// SetPaused pauses or resumes playback and publishes the change as domain.PlaybackPaused
+func (ap *AudioPanel) SetPaused(paused bool) {
+	ap.mu.Lock()
 	if ap.ctrl == nil {
+		ap.mu.Unlock()
 		return
 	}
-	speaker.Lock()
-	ap.ctrl.Paused = !ap.ctrl.Paused
-	speaker.Unlock()
+	ap.output.Lock()
+	ap.ctrl.Paused = paused
+	ap.output.Unlock()
+	ap.mu.Unlock()
+	if output, ok := ap.output.(pauser); ok {
+		output.SetPaused(paused)
+	}
+	domain.Publish(domain.PlaybackPaused{Paused: paused})
 }
 
 func (ap *AudioPanel) Duration(e clients.Enclosure) time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
 	byteCount := int(e.Length)
 	numSamples := byteCount / ap.Format.Width()
 	return ap.sampleRate.D(numSamples)
@@ -61,84 +107,202 @@ func FetchAudioPanel() *AudioPanel {
 	return panel
 }
 
-func (ap *AudioPanel) SubscribeToState(subscriber PlayerStateSubscriber) {
-	ap.subscribers = append(ap.subscribers, subscriber)
-}
-
 // AttachLogger implements setter injection of a log.Logger
 func (ap *AudioPanel) AttachLogger(logger *log.Logger) *AudioPanel {
 	ap.logger = logger
 	return ap
 }
 
-// SetPublishCallback is where the function to update on publish should be supplied
-// Probably don't pass anything other than QueueUpdateDraw
-func (ap *AudioPanel) SetPublishCallback(callback func(func())) {
-	ap.callback = callback
+// SetCachePath sets the directory that episodes played with Play are buffered to
+func (ap *AudioPanel) SetCachePath(cachePath string) *AudioPanel {
+	ap.cachePath = cachePath
+	return ap
+}
+
+// AttachOutput implements setter injection of the Output that audio is played through
+func (ap *AudioPanel) AttachOutput(output Output) *AudioPanel {
+	ap.output = output
+	return ap
+}
+
+// finisher is implemented by streamers that can run dry before they are finished, as an
+// episode does when playback catches up with its download, see clients.Decoder
+type finisher interface {
+	Finished() bool
+}
+
+// waitForData streams silence while its streamer has run dry but is not finished, so
+// that playback waits on the download rather than ending the episode
+type waitForData struct {
+	beep.Streamer
+	finisher finisher
+}
+
+func (w waitForData) Stream(samples [][2]float64) (int, bool) {
+	// Checked first, as all of a finished download is there to be streamed
+	finished := w.finisher.Finished()
+	n, ok := w.Streamer.Stream(samples)
+	if n == len(samples) || finished {
+		return n, ok
+	}
+	for i := n; i < len(samples); i++ {
+		samples[i] = [2]float64{}
+	}
+	return len(samples), true
 }
 
 func (ap *AudioPanel) SetStreamer(format beep.Format, streamer beep.StreamSeekCloser) {
-	speaker.Clear()
-	speaker.Lock()
+	ap.output.Clear()
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.output.Lock()
 	if ap.streamer != nil {
 		_ = ap.streamer.Close()
 	}
-	speaker.Unlock()
+	ap.output.Unlock()
 	ap.Format = format
 	ap.streamer = streamer
 	ap.sampleRate = format.SampleRate
-	ap.ctrl = &beep.Ctrl{Streamer: beep.Loop(-1, streamer)}            // used for pausing
-	ap.resampler = beep.ResampleRatio(4, 1, ap.ctrl)                   // can change playback speed.
-	ap.volume = &effects.Volume{Streamer: ap.ctrl, Base: 2, Volume: 0} // Volume: -0.1 to 5 tested range. 0 is system volume
+
+	var source beep.Streamer = streamer
+	if f, ok := streamer.(finisher); ok {
+		source = waitForData{Streamer: streamer, finisher: f}
+	}
+	// used for pausing, the queue is advanced once the stream is finished
+	ap.ctrl = &beep.Ctrl{Streamer: beep.Seq(source, beep.Callback(func() { go ap.advance() }))}
+	ap.resampler = beep.ResampleRatio(4, ap.speed, ap.ctrl) // can change playback speed.
+	ap.volume = &effects.Volume{Streamer: ap.resampler, Base: 2}
+	ap.applyGain()
 }
 
+// Seek moves playback of the current stream to the position, which is clamped to the
+// length of the stream
+func (ap *AudioPanel) Seek(position time.Duration) error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.streamer == nil {
+		return ErrNothingPlaying
+	}
+	ap.output.Lock()
+	defer ap.output.Unlock()
+
+	sample := ap.sampleRate.N(position)
+	if length := ap.streamer.Len(); sample >= length {
+		sample = length - 1
+	}
+	if sample < 0 {
+		sample = 0
+	}
+	return ap.streamer.Seek(sample)
+}
+
+// SetVolume sets the gain applied to playback, 1 leaves the audio as it is and 0 mutes it.
+// The gain is clamped to between 0 and MaxVolume.
+func (ap *AudioPanel) SetVolume(gain float64) error {
+	if math.IsNaN(gain) || math.IsInf(gain, 0) {
+		return fmt.Errorf("volume must be a number, got %v", gain)
+	}
+	gain = math.Max(0, math.Min(gain, MaxVolume))
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.output.Lock()
+	ap.gain = gain
+	ap.applyGain()
+	ap.output.Unlock()
+
+	return nil
+}
+
+// applyGain converts the linear gain to the exponent used by effects.Volume, the caller
+// must hold ap.mu, and the output lock if audio is playing
+func (ap *AudioPanel) applyGain() {
+	if ap.volume == nil {
+		return
+	}
+	ap.volume.Silent = ap.gain == 0
+	if !ap.volume.Silent {
+		ap.volume.Volume = math.Log2(ap.gain)
+	}
+}
+
+// SetSpeed sets the playback speed as a ratio of real time, i.e. 1.5 is half as fast
+// again. The speed is clamped to between MinSpeed and MaxSpeed.
+func (ap *AudioPanel) SetSpeed(speed float64) error {
+	if math.IsNaN(speed) || math.IsInf(speed, 0) || speed <= 0 {
+		return fmt.Errorf("speed must be a positive number, got %v", speed)
+	}
+	speed = math.Max(MinSpeed, math.Min(speed, MaxSpeed))
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.output.Lock()
+	ap.speed = speed
+	if ap.resampler != nil {
+		ap.resampler.SetRatio(speed)
+	}
+	ap.output.Unlock()
+
+	return nil
+}
+
+// SpawnPublisher starts periodically publishing the player state as a domain.PositionTick
 func (ap *AudioPanel) SpawnPublisher() {
 	ap.clock = time.NewTicker(time.Second / 2)
 	publisher := func() {
 		for range ap.clock.C {
-			for _, sub := range ap.subscribers {
-				ap.callback(func() {
-					sub.OnUpdate()
-				})
-			}
+			state := ap.GetPlayerState()
+			domain.Publish(domain.PositionTick{
+				Position: state.Position,
+				Length:   state.Length,
+				Playing:  state.Playing,
+				Speed:    state.Speed,
+			})
 		}
 	}
 	go publisher()
 }
 
-func (ap *AudioPanel) NoBufferPlayFromUrl(url string) {
-	var err error
+// NoBufferPlayFromUrl plays the audio at url directly from the response body, without
+// buffering it to disk
+func (ap *AudioPanel) NoBufferPlayFromUrl(url string) error {
 	ap.logger.Println("PlayFromUrl call")
 
 	audio, err := ap.AudioRequest(url)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	streamer, format, err := clients.StreamDecode(audio)
 	if err != nil {
-		log.Fatal(err)
+		_ = audio.Close()
+		return fmt.Errorf("decode error: %v", err)
 	}
 	ap.SetStreamer(format, streamer)
 
-	err = speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
+	err = ap.output.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	ap.play()
-}
 
-func (ap *AudioPanel) PlayFromUrl(url string, logger *log.Logger, cachePath string) {
-	var err error
+	return nil
+}
 
-	streamer, format := clients.TcpDiskBufferedStreamer(url, logger, cachePath)
+// PlayFromUrl plays the audio at url, buffering it to disk in cachePath as it downloads.
+// The audio is cached under id, the id of the episode it belongs to
+func (ap *AudioPanel) PlayFromUrl(url string, id string, logger *log.Logger, cachePath string) error {
+	streamer, format, err := clients.TcpDiskBufferedStreamer(url, id, logger, cachePath)
+	if err != nil {
+		return fmt.Errorf("could not stream %s: %v", url, err)
+	}
 	ap.SetStreamer(format, streamer)
 
-	err = speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
+	err = ap.output.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	ap.play()
+
+	return nil
 }
 
 // AudioRequest is the bare minimum HTTP request function to get an audio stream.
@@ -146,33 +310,43 @@ func (ap *AudioPanel) PlayFromUrl(url string, logger *log.Logger, cachePath stri
 // resp.Body is of this type so can simply be returned following the request.
 func (ap *AudioPanel) AudioRequest(url string) (io.ReadCloser, error) {
 	ap.logger.Printf("Requesting streaming audio from %s", url)
-	resp, err := http.Get(url)
+	resp, err := clients.Fetch(url)
 	if err != nil {
 		return nil, fmt.Errorf("GET error: %v", err)
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status error: %v", resp.StatusCode)
+	}
+
 	return resp.Body, nil
 }
 
 // play Plays the stream referenced by AudioPanel.streamer at the volume of AudioPanel.volume
 func (ap *AudioPanel) play() {
 	ap.logger.Println("Playing audio")
-	speaker.Play(ap.volume)
+	ap.mu.Lock()
+	volume := ap.volume
+	ap.mu.Unlock()
+	ap.output.Play(volume)
 }
 
 // GetPlayerState returns a PlayerState value that represents a snapshot of the user relevant
 // player state at the time this method was called
 func (ap *AudioPanel) GetPlayerState() PlayerState {
-	state := PlayerState{}
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	state := PlayerState{Volume: ap.gain, Speed: ap.speed}
 	if ap.streamer != nil {
+		ap.output.Lock()
+		defer ap.output.Unlock()
 		if ap.streamer.Position() <= 0 {
 			return state
 		}
-		speaker.Lock()
 		state.Position = ap.sampleRate.D(ap.streamer.Position())
 		state.Length = ap.sampleRate.D(ap.streamer.Len())
 		state.Playing = !ap.ctrl.Paused
-		speaker.Unlock()
 	}
 
 	return state
@@ -0,0 +1,156 @@
+package audiopanel
+
+import (
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// download is a tone that arrives a part at a time, standing in for an episode that
+// playback can catch up with while it downloads
+type download struct {
+	tone
+	available int
+	finished  bool
+}
+
+func (d *download) Stream(samples [][2]float64) (int, bool) {
+	d.mu.Lock()
+	available := d.available
+	d.mu.Unlock()
+	if available < len(samples)+d.Position() {
+		samples = samples[:available-d.Position()]
+	}
+	return d.tone.Stream(samples)
+}
+
+func (d *download) Finished() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.finished
+}
+
+// arrive makes the rest of the download available and finishes it
+func (d *download) arrive() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.available = d.len
+	d.finished = true
+}
+
+func TestCatchingUpWithADownloadDoesNotAdvance(t *testing.T) {
+	quietClients()
+	requested := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	output := NewNullOutput(0)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	ap.SetCachePath(t.TempDir())
+	ap.queue = []*clients.Item{clients.ItemFromUrl(server.URL+"/next.mp3", "Next")}
+	audio := &download{tone: tone{len: 2 * int(testRate)}, available: int(testRate) / 2}
+
+	start(t, ap, audio)
+	eventually(t, "playback to catch up with the download", func() bool {
+		return audio.Position() == audio.available
+	})
+	select {
+	case <-requested:
+		t.Fatal("the queue was advanced while waiting on the download")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if state := ap.GetPlayerState(); !state.Playing {
+		t.Error("the player state is not playing while waiting on the download")
+	}
+
+	audio.arrive()
+	eventually(t, "the rest of the download to be played", func() bool { return audio.Position() == audio.len })
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queue was not advanced once the download was played")
+	}
+}
+
+func TestPlayerStateIsSafeToReadWhilePlaying(t *testing.T) {
+	output := NewNullOutput(0)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	start(t, ap, &tone{len: 60 * int(testRate)})
+
+	var controls sync.WaitGroup
+	controls.Add(1)
+	go func() {
+		defer controls.Done()
+		for i := 0; i < 100; i++ {
+			ap.SetVolume(float64(i%10) / 10)
+			_ = ap.SetSpeed(1 + float64(i%4)/4)
+			ap.PlayPause()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = ap.GetPlayerState()
+	}
+	controls.Wait()
+}
+
+func TestSpeedAndVolumeAreValidated(t *testing.T) {
+	output := NewNullOutput(1)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	audio := &tone{len: 60 * int(testRate)}
+	start(t, ap, audio)
+
+	for _, bad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 0, -1} {
+		if err := ap.SetSpeed(bad); err == nil {
+			t.Errorf("a speed of %v was accepted", bad)
+		}
+	}
+	for _, bad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := ap.SetVolume(bad); err == nil {
+			t.Errorf("a volume of %v was accepted", bad)
+		}
+	}
+	if state := ap.GetPlayerState(); state.Speed != 1 || state.Volume != 1 {
+		t.Errorf("refused settings changed the speed to %v and the volume to %v", state.Speed, state.Volume)
+	}
+
+	tests := []struct {
+		set   float64
+		speed float64
+		gain  float64
+	}{
+		{0.1, MinSpeed, 0.1},
+		{1.5, 1.5, 1.5},
+		{100, MaxSpeed, MaxVolume},
+		{-1, MinSpeed, 0},
+	}
+	for _, test := range tests {
+		if test.set > 0 {
+			if err := ap.SetSpeed(test.set); err != nil {
+				t.Fatal(err)
+			}
+			if speed := ap.GetPlayerState().Speed; speed != test.speed {
+				t.Errorf("a speed of %v gave %v, expected %v", test.set, speed, test.speed)
+			}
+		}
+		if err := ap.SetVolume(test.set); err != nil {
+			t.Fatal(err)
+		}
+		if gain := ap.GetPlayerState().Volume; gain != test.gain {
+			t.Errorf("a volume of %v gave %v, expected %v", test.set, gain, test.gain)
+		}
+	}
+
+	// The stream is still playing after all of that
+	position := audio.Position()
+	eventually(t, "playback to carry on", func() bool { return audio.Position() > position })
+}
@@ -0,0 +1,350 @@
+package audiopanel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	SpeakerBackend = "speaker"
+	NullBackend    = "null"
+	WavBackend     = "wav"
+)
+
+// Output is the abstraction of the sink that the AudioPanel plays through. It mirrors the
+// package level functions of beep/speaker so that the speaker can be swapped out for a
+// sink that does not require a sound card.
+type Output interface {
+	Init(sampleRate beep.SampleRate, bufferSize int) error
+	Play(s ...beep.Streamer)
+	Clear()
+	Lock()
+	Unlock()
+	Close() error
+}
+
+// NewOutput returns the Output implementation selected by the backend in the config
+func NewOutput(conf app.Output) (Output, error) {
+	switch conf.Backend {
+	case "", SpeakerBackend:
+		return &SpeakerOutput{}, nil
+	case NullBackend:
+		return NewNullOutput(conf.Speed), nil
+	case WavBackend:
+		if conf.Path == "" {
+			return nil, fmt.Errorf("output: the %s backend requires a path", WavBackend)
+		}
+		return NewWavOutput(conf.Path, conf.Speed), nil
+	}
+
+	return nil, fmt.Errorf("output: unknown backend %q", conf.Backend)
+}
+
+// SpeakerOutput plays through the system audio device using beep/speaker
+type SpeakerOutput struct{}
+
+func (s *SpeakerOutput) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	return speaker.Init(sampleRate, bufferSize)
+}
+
+func (s *SpeakerOutput) Play(streamers ...beep.Streamer) {
+	speaker.Play(streamers...)
+}
+
+func (s *SpeakerOutput) Clear() {
+	speaker.Clear()
+}
+
+func (s *SpeakerOutput) Lock() {
+	speaker.Lock()
+}
+
+func (s *SpeakerOutput) Unlock() {
+	speaker.Unlock()
+}
+
+func (s *SpeakerOutput) Close() error {
+	speaker.Close()
+	return nil
+}
+
+// pauser is implemented by the outputs that stop consuming audio while playback is
+// paused, rather than consuming the silence of the paused stream
+type pauser interface {
+	SetPaused(paused bool)
+}
+
+// sinkOutput is the software equivalent of beep/speaker. It pulls samples from a mixer on
+// its own goroutine and hands them to write. The rate samples are consumed at is real time
+// multiplied by speed, a speed of 0 or less consumes samples as fast as they can be produced.
+// Nothing is consumed while the mixer is empty or playback is paused, so that an idle
+// output neither spins nor writes silence.
+type sinkOutput struct {
+	mu         sync.Mutex
+	wake       *sync.Cond
+	mixer      beep.Mixer
+	samples    [][2]float64
+	sampleRate beep.SampleRate
+	speed      float64
+	paused     bool
+	done       chan struct{}
+	open       func(sampleRate beep.SampleRate) error
+	write      func(samples [][2]float64) error
+	finish     func() error
+}
+
+func (s *sinkOutput) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.stop(); err != nil {
+		return err
+	}
+
+	if s.wake == nil {
+		s.wake = sync.NewCond(&s.mu)
+	}
+	s.mixer = beep.Mixer{}
+	s.paused = false
+	s.samples = make([][2]float64, bufferSize)
+	s.sampleRate = sampleRate
+
+	if s.open != nil {
+		if err := s.open(sampleRate); err != nil {
+			return err
+		}
+	}
+
+	s.done = make(chan struct{})
+	go s.run(s.done)
+
+	return nil
+}
+
+// run is the consuming loop, it is throttled so that the consumed audio keeps pace
+// with the wall clock scaled by speed, and waits while there is nothing to consume
+func (s *sinkOutput) run(done chan struct{}) {
+	start := time.Now()
+	consumed := 0
+	for {
+		s.mu.Lock()
+		waited := false
+		for !closed(done) && (s.paused || s.mixer.Len() == 0) {
+			waited = true
+			s.wake.Wait()
+		}
+		if closed(done) {
+			s.mu.Unlock()
+			return
+		}
+		if waited {
+			// The time spent waiting is not made up for by consuming faster
+			start, consumed = time.Now(), 0
+		}
+
+		n, _ := s.mixer.Stream(s.samples)
+		var err error
+		if s.write != nil {
+			err = s.write(s.samples[:n])
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+
+		consumed += n
+		if s.speed > 0 {
+			due := start.Add(time.Duration(float64(s.sampleRate.D(consumed)) / s.speed))
+			time.Sleep(time.Until(due))
+		}
+	}
+}
+
+// closed reports whether the consuming goroutine has been told to stop
+func closed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop ends the consuming goroutine and finalises any output, the caller must hold s.mu
+func (s *sinkOutput) stop() error {
+	if s.done == nil {
+		return nil
+	}
+	close(s.done)
+	s.done = nil
+	s.wake.Broadcast()
+	if s.finish != nil {
+		return s.finish()
+	}
+	return nil
+}
+
+func (s *sinkOutput) Play(streamers ...beep.Streamer) {
+	s.mu.Lock()
+	s.mixer.Add(streamers...)
+	s.signal()
+	s.mu.Unlock()
+}
+
+// SetPaused stops or resumes consuming audio, see pauser
+func (s *sinkOutput) SetPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	s.signal()
+	s.mu.Unlock()
+}
+
+// signal wakes the consuming goroutine if it is waiting, the caller must hold s.mu
+func (s *sinkOutput) signal() {
+	if s.wake != nil {
+		s.wake.Broadcast()
+	}
+}
+
+func (s *sinkOutput) Clear() {
+	s.mu.Lock()
+	s.mixer.Clear()
+	s.mu.Unlock()
+}
+
+func (s *sinkOutput) Lock() {
+	s.mu.Lock()
+}
+
+func (s *sinkOutput) Unlock() {
+	s.mu.Unlock()
+}
+
+func (s *sinkOutput) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop()
+}
+
+// NullOutput discards everything that is played through it. It is intended for running
+// the player on machines without a sound card.
+type NullOutput struct {
+	sinkOutput
+}
+
+// NewNullOutput initialises a NullOutput that consumes samples at speed times real time
+func NewNullOutput(speed float64) *NullOutput {
+	return &NullOutput{sinkOutput{speed: speed}}
+}
+
+// WavOutput records everything that is played through it to a 16 bit stereo WAV file.
+// The file is truncated each time the output is initialised.
+type WavOutput struct {
+	sinkOutput
+	Path    string
+	file    *os.File
+	written uint32
+}
+
+// NewWavOutput initialises a WavOutput writing to path at speed times real time
+func NewWavOutput(path string, speed float64) *WavOutput {
+	w := &WavOutput{Path: path}
+	w.sinkOutput = sinkOutput{speed: speed, open: w.open, write: w.write, finish: w.finish}
+	return w
+}
+
+const (
+	wavHeaderLen     = 44
+	wavChannels      = 2
+	wavBytesPerFrame = wavChannels * 2
+)
+
+func (w *WavOutput) open(sampleRate beep.SampleRate) error {
+	file, err := os.Create(w.Path)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.written = 0
+	return w.writeHeader(sampleRate)
+}
+
+// writeHeader writes the RIFF header for the data written so far at the start of the file
+func (w *WavOutput) writeHeader(sampleRate beep.SampleRate) error {
+	header := struct {
+		Riff          [4]byte
+		ChunkSize     uint32
+		Wave          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		NumChannels   uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		Riff:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     wavHeaderLen - 8 + w.written,
+		Wave:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1,
+		NumChannels:   wavChannels,
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate) * wavBytesPerFrame,
+		BlockAlign:    wavBytesPerFrame,
+		BitsPerSample: 16,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      w.written,
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err := w.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (w *WavOutput) write(samples [][2]float64) error {
+	buf := make([]byte, len(samples)*wavBytesPerFrame)
+	for i := range samples {
+		for c := range samples[i] {
+			val := samples[i][c]
+			if val < -1 {
+				val = -1
+			}
+			if val > +1 {
+				val = +1
+			}
+			binary.LittleEndian.PutUint16(buf[i*4+c*2:], uint16(int16(val*(1<<15-1))))
+		}
+	}
+	n, err := w.file.Write(buf)
+	w.written += uint32(n)
+	return err
+}
+
+func (w *WavOutput) finish() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.writeHeader(w.sampleRate)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
@@ -0,0 +1,246 @@
+package audiopanel
+
+import (
+	"encoding/binary"
+	"github.com/faiface/beep"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testRate = beep.SampleRate(8000)
+
+var testFormat = beep.Format{SampleRate: testRate, NumChannels: 2, Precision: 2}
+
+// tone is a StreamSeekCloser of a constant level, standing in for a decoded episode
+type tone struct {
+	mu     sync.Mutex
+	pos    int
+	len    int
+	level  float64
+	closed bool
+}
+
+func (t *tone) Stream(samples [][2]float64) (n int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for n < len(samples) && t.pos < t.len {
+		samples[n] = [2]float64{t.level, t.level}
+		n++
+		t.pos++
+	}
+	return n, n > 0
+}
+
+func (t *tone) Err() error    { return nil }
+func (t *tone) Len() int      { return t.len }
+func (t *tone) Position() int { t.mu.Lock(); defer t.mu.Unlock(); return t.pos }
+func (t *tone) Close() error  { t.closed = true; return nil }
+
+func (t *tone) Seek(p int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pos = p
+	return nil
+}
+
+var initLoggers sync.Once
+
+// quietClients gives the clients package loggers that discard what they are given. It
+// is done once, as episodes queued by one test may still be advancing in the next.
+func quietClients() {
+	initLoggers.Do(func() {
+		clients.InitLoggers(func(prefix string) *log.Logger { return log.New(io.Discard, prefix, 0) })
+	})
+}
+
+// newTestPanel returns an AudioPanel playing through output, separate from the panel of
+// the application
+func newTestPanel(output Output) *AudioPanel {
+	return &AudioPanel{output: output, gain: 1, speed: 1, logger: log.New(io.Discard, "", 0)}
+}
+
+// start plays the audio through the panel
+func start(t *testing.T, ap *AudioPanel, audio beep.StreamSeekCloser) {
+	t.Helper()
+	ap.SetStreamer(testFormat, audio)
+	if err := ap.output.Init(testRate, testRate.N(time.Second/10)); err != nil {
+		t.Fatal(err)
+	}
+	ap.play()
+}
+
+// eventually fails the test if the condition does not hold within a few seconds
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// fileSize returns the size of the file at path, or -1 if it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+func TestWavOutputRecordsPlayback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	output := NewWavOutput(path, 0)
+	ap := newTestPanel(output)
+	audio := &tone{len: int(testRate), level: 0.5}
+
+	start(t, ap, audio)
+	eventually(t, "the tone to be played", func() bool { return audio.Position() == audio.len })
+	// The mixer drops the drained stream on its next pass, which the output then waits on
+	eventually(t, "the output to go idle", func() bool {
+		output.Lock()
+		defer output.Unlock()
+		return output.mixer.Len() == 0
+	})
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataSize := binary.LittleEndian.Uint32(content[40:44])
+	if int(dataSize) != len(content)-wavHeaderLen {
+		t.Errorf("header gives %d bytes of data, the file has %d", dataSize, len(content)-wavHeaderLen)
+	}
+	// The resampler may hold back a few samples at the end of the stream, and the mixer
+	// pads its last buffer with silence
+	frames := int(dataSize) / wavBytesPerFrame
+	if frames < audio.len-16 || frames > audio.len+testRate.N(time.Second/10) {
+		t.Errorf("recorded %d frames of a %d frame tone", frames, audio.len)
+	}
+	middle := wavHeaderLen + frames/2*wavBytesPerFrame
+	if level := int16(binary.LittleEndian.Uint16(content[middle:])); level < 16000 || level > 16500 {
+		t.Errorf("recorded a level of %d for a tone of 0.5", level)
+	}
+}
+
+func TestSinkOutputWaitsWhilePaused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	output := NewWavOutput(path, 1)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	audio := &tone{len: 60 * int(testRate), level: 0.5}
+
+	start(t, ap, audio)
+	eventually(t, "playback to start", func() bool { return audio.Position() > 0 })
+
+	ap.SetPaused(true)
+	paused := audio.Position()
+	size := fileSize(path)
+	time.Sleep(300 * time.Millisecond)
+	if position := audio.Position(); position != paused {
+		t.Errorf("playback moved from %d to %d while paused", paused, position)
+	}
+	if grown := fileSize(path); grown != size {
+		t.Errorf("the recording grew from %d to %d bytes while paused", size, grown)
+	}
+	if state := ap.GetPlayerState(); state.Playing {
+		t.Error("the player state is playing while paused")
+	}
+
+	ap.SetPaused(false)
+	eventually(t, "playback to resume", func() bool { return audio.Position() > paused })
+}
+
+func TestNullOutputSeeks(t *testing.T) {
+	output := NewNullOutput(1)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	audio := &tone{len: 60 * int(testRate)}
+
+	start(t, ap, audio)
+	if err := ap.Seek(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if position := ap.GetPlayerState().Position; position < 30*time.Second || position > 31*time.Second {
+		t.Errorf("seeking to 30s gave a position of %v", position)
+	}
+
+	if err := ap.Seek(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if position := audio.Position(); position != audio.len-1 {
+		t.Errorf("seeking past the end gave sample %d of %d", position, audio.len)
+	}
+}
+
+func TestNullOutputAdvancesTheQueue(t *testing.T) {
+	quietClients()
+	requested := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	output := NewNullOutput(0)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	ap.SetCachePath(t.TempDir())
+	ap.queue = []*clients.Item{clients.ItemFromUrl(server.URL+"/next.mp3", "Next")}
+	audio := &tone{len: int(testRate)}
+
+	start(t, ap, audio)
+	select {
+	case path := <-requested:
+		if path != "/next.mp3" {
+			t.Errorf("advanced to %s, expected /next.mp3", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queue was not advanced once the episode finished")
+	}
+	eventually(t, "the queue to be emptied", func() bool { return len(ap.Queue()) == 0 })
+}
+
+func TestEnqueueAfterTheLastEpisodeFinishedPlays(t *testing.T) {
+	quietClients()
+	requested := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	output := NewNullOutput(0)
+	defer func() { _ = output.Close() }()
+	ap := newTestPanel(output)
+	ap.SetCachePath(t.TempDir())
+	ap.playing = clients.ItemFromUrl(server.URL+"/first.mp3", "First")
+	audio := &tone{len: int(testRate)}
+
+	start(t, ap, audio)
+	eventually(t, "the episode to finish", func() bool { return ap.Playing() == nil })
+
+	// The episode is not found, all that matters is that it was requested
+	_ = ap.Enqueue(clients.ItemFromUrl(server.URL+"/next.mp3", "Next"))
+	select {
+	case path := <-requested:
+		if path != "/next.mp3" {
+			t.Errorf("played %s, expected /next.mp3", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the episode enqueued once the last one finished was not played")
+	}
+}
@@ -0,0 +1,60 @@
+package audiopanel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParsePosition interprets a user supplied seek target. Absolute positions are given as
+// seconds, as [hh:]mm:ss or as a Go duration such as 1m30s. A leading + or - makes the
+// position relative to current.
+func ParsePosition(arg string, current time.Duration) (time.Duration, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return 0, fmt.Errorf("no position given")
+	}
+
+	sign := 0
+	switch arg[0] {
+	case '+':
+		sign = 1
+		arg = arg[1:]
+	case '-':
+		sign = -1
+		arg = arg[1:]
+	}
+
+	offset, err := parseClock(arg)
+	if err != nil {
+		return 0, err
+	}
+
+	if sign == 0 {
+		return offset, nil
+	}
+	return current + time.Duration(sign)*offset, nil
+}
+
+// parseClock parses an unsigned position in one of the formats accepted by ParsePosition
+func parseClock(arg string) (time.Duration, error) {
+	if d, err := time.ParseDuration(arg); err == nil {
+		return d, nil
+	}
+
+	var position time.Duration
+	parts := strings.Split(arg, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid position %q", arg)
+	}
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid position %q", arg)
+		}
+		position = position*60 + time.Duration(value*float64(time.Second))
+	}
+
+	return position, nil
+}
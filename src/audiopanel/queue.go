@@ -0,0 +1,86 @@
+package audiopanel
+
+import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+)
+
+// ErrQueueEmpty is returned by Skip when there is nothing queued to skip to
+var ErrQueueEmpty = fmt.Errorf("the queue is empty")
+
+// Play starts playback of the episode, buffering it to the cache path as it downloads.
+// Audio already in the cache is played without any requests being made. The rest of
+// the application is told about the new episode with domain.EpisodeStarted
+func (ap *AudioPanel) Play(episode *clients.Item) error {
+	clients.AdoptCachedAudio(ap.cachePath, episode.Enclosure.Url, episode.ID())
+	err := ap.PlayFromUrl(episode.Enclosure.Url, episode.ID(), ap.logger, ap.cachePath)
+	if err != nil {
+		return err
+	}
+	ap.queueLock.Lock()
+	ap.playing = episode
+	ap.queueLock.Unlock()
+	domain.Publish(domain.EpisodeStarted{Episode: episode})
+
+	return nil
+}
+
+// Playing returns the episode that was last started with Play, or nil
+func (ap *AudioPanel) Playing() *clients.Item {
+	ap.queueLock.Lock()
+	defer ap.queueLock.Unlock()
+	return ap.playing
+}
+
+// Enqueue adds episodes to the end of the queue, playing the first of them straight
+// away if nothing is playing
+func (ap *AudioPanel) Enqueue(episodes ...*clients.Item) error {
+	ap.queueLock.Lock()
+	ap.queue = append(ap.queue, episodes...)
+	idle := ap.playing == nil
+	ap.queueLock.Unlock()
+
+	if idle {
+		return ap.Skip()
+	}
+	return nil
+}
+
+// Queue returns the episodes waiting to be played, in order
+func (ap *AudioPanel) Queue() []*clients.Item {
+	ap.queueLock.Lock()
+	defer ap.queueLock.Unlock()
+	return append([]*clients.Item{}, ap.queue...)
+}
+
+// Skip abandons the current episode and plays the next one in the queue
+func (ap *AudioPanel) Skip() error {
+	ap.queueLock.Lock()
+	if len(ap.queue) == 0 {
+		ap.queueLock.Unlock()
+		return ErrQueueEmpty
+	}
+	next := ap.queue[0]
+	ap.queue = ap.queue[1:]
+	ap.queueLock.Unlock()
+
+	return ap.Play(next)
+}
+
+// advance is called once the current stream has drained, it publishes
+// domain.EpisodeFinished and moves on to the next episode in the queue, if there is one.
+// Otherwise nothing is playing, so that the next episode enqueued is played straight away.
+func (ap *AudioPanel) advance() {
+	domain.Publish(domain.EpisodeFinished{Episode: ap.Playing()})
+	err := ap.Skip()
+	if err == nil {
+		return
+	}
+	ap.queueLock.Lock()
+	ap.playing = nil
+	ap.queueLock.Unlock()
+	if err != ErrQueueEmpty {
+		ap.logger.Printf("Could not advance the queue: %v", err)
+	}
+}
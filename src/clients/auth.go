@@ -0,0 +1,109 @@
+package clients
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Credentials are sent with the requests for a private feed and the enclosures in it.
+// Username and Password are sent with basic auth, Token as a bearer token and Headers
+// as they are given.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+	Headers  map[string]string
+}
+
+// apply sets the headers of the credentials on the request
+func (c *Credentials) apply(req *http.Request) {
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// remove deletes the headers of the credentials from the request
+func (c *Credentials) remove(req *http.Request) {
+	req.Header.Del("Authorization")
+	for name := range c.Headers {
+		req.Header.Del(name)
+	}
+}
+
+var (
+	// authorised maps the urls of private feeds, and of the enclosures in them, to the
+	// credentials sent when requesting them
+	authorised     = map[string]*Credentials{}
+	authorisedLock sync.RWMutex
+)
+
+// Authorise registers the credentials to send when requesting the feed at url. They are
+// also sent when requesting the enclosures of the feed that are served from the same
+// host, so that the audio of private feeds can be downloaded too.
+func Authorise(url string, credentials *Credentials) {
+	authorisedLock.Lock()
+	defer authorisedLock.Unlock()
+	authorised[url] = credentials
+}
+
+// credentialsFor returns the credentials registered for the url, or nil
+func credentialsFor(url string) *Credentials {
+	authorisedLock.RLock()
+	defer authorisedLock.RUnlock()
+	return authorised[url]
+}
+
+// shareCredentials registers the credentials of the url from for the url to as well, if
+// it has some and to is on the same host. Credentials are never sent to other hosts.
+func shareCredentials(from, to string) {
+	credentials := credentialsFor(from)
+	if credentials == nil || from == to || !sameHost(from, to) {
+		return
+	}
+	Authorise(to, credentials)
+}
+
+// sameHost reports whether the urls are served from the same host
+func sameHost(a, b string) bool {
+	aUrl, aErr := url.Parse(a)
+	bUrl, bErr := url.Parse(b)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+	return strings.EqualFold(aUrl.Host, bUrl.Host)
+}
+
+// authoriseEnclosures shares the credentials of the feed at url with its enclosures
+func (feed *RSSFeed) authoriseEnclosures(url string) {
+	if credentialsFor(url) == nil {
+		return
+	}
+	for _, channel := range feed.Channel {
+		for _, item := range channel.Item {
+			shareCredentials(url, strings.TrimSpace(item.Enclosure.Url))
+		}
+	}
+}
+
+// redirectCredentials removes the credentials sent with the first request from a
+// redirect to another host, and sends any registered for the url redirected to.
+// http.Client drops the Authorization header itself, but not the custom headers of the
+// credentials.
+func redirectCredentials(req *http.Request, via []*http.Request) {
+	first := via[0]
+	if credentials := credentialsFor(first.URL.String()); credentials != nil &&
+		!strings.EqualFold(first.URL.Host, req.URL.Host) {
+		credentials.remove(req)
+	}
+	if credentials := credentialsFor(req.URL.String()); credentials != nil {
+		credentials.apply(req)
+	}
+}
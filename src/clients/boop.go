@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -64,7 +65,7 @@ func boopDecode(rc io.ReadCloser, logger *log.Logger) (s *Decoder, format beep.F
 		NumChannels: gomp3NumChannels,
 		Precision:   gomp3Precision,
 	}
-	return &Decoder{rc, d, format, 0, nil, 0, logger}, format, nil
+	return &Decoder{closer: rc, d: d, f: format, logger: logger}, format, nil
 }
 
 // Decoder is a ripoff of the streamer that mp3.Decode returns but with a Decoder.SetLength. All the methods
@@ -75,8 +76,11 @@ type Decoder struct {
 	f      beep.Format
 	pos    int
 	err    error
-	len    int
 	logger *log.Logger
+	// lengthLock guards len and complete, which are set when the download finishes
+	lengthLock sync.Mutex
+	len        int
+	complete   bool
 }
 
 func (d *Decoder) Stream(samples [][2]float64) (n int, ok bool) {
@@ -108,6 +112,8 @@ func (d *Decoder) Err() error {
 }
 
 func (d *Decoder) Len() int {
+	d.lengthLock.Lock()
+	defer d.lengthLock.Unlock()
 	bytesLen := d.d.Length()
 	if bytesLen != 0 {
 		d.len = int(bytesLen)
@@ -117,9 +123,26 @@ func (d *Decoder) Len() int {
 }
 
 func (d *Decoder) SetLength(length int) {
+	d.lengthLock.Lock()
+	defer d.lengthLock.Unlock()
 	d.len = length
 }
 
+// Finish records that no more audio will arrive, as the download has finished or failed
+func (d *Decoder) Finish() {
+	d.lengthLock.Lock()
+	defer d.lengthLock.Unlock()
+	d.complete = true
+}
+
+// Finished reports whether the audio will not grow any longer, so that running out of
+// it is the end of the episode rather than playback catching up with the download
+func (d *Decoder) Finished() bool {
+	d.lengthLock.Lock()
+	defer d.lengthLock.Unlock()
+	return d.complete || d.err != nil
+}
+
 func (d *Decoder) Position() int {
 	return d.pos / gomp3BytesPerFrame
 }
@@ -145,8 +168,9 @@ func (d *Decoder) Close() error {
 }
 
 // TcpDiskBufferedStreamer is basically a proxy to some hacked together beep source code, all credit to them, unless
-// the code looks a bit messy, that's probably us.
-func TcpDiskBufferedStreamer(url string, logger *log.Logger, cachePath string) (streamer *Decoder, format beep.Format) {
+// the code looks a bit messy, that's probably us. The audio is cached under the id of the episode, see Item.ID,
+// so that it is found again if the url changes.
+func TcpDiskBufferedStreamer(url string, id string, logger *log.Logger, cachePath string) (streamer *Decoder, format beep.Format, err error) {
 	logger.Printf("Attempting to set up streaming audio")
 
 	var (
@@ -154,38 +178,64 @@ func TcpDiskBufferedStreamer(url string, logger *log.Logger, cachePath string) (
 		started chan *SizedResult
 	)
 
-	fileName := filepath.FromSlash(cachePath + url2FileName(url, logger))
+	fileName := filepath.Join(cachePath, episodeFileName(id))
 
+	// Only a complete download is found under fileName, until then it is streamed from
+	// the partial file being written
 	if fileInfo, err := os.Stat(fileName); err != nil || fileInfo.Size() == 0 {
 		logger.Printf("starting download")
-		started, done = asyncDownloadAudio(fileName, url, logger)
+		started, done = asyncDownloadAudio(fileName, url, id, logger)
+		fileName = partialName(fileName)
 	} else {
-		logger.Printf("os.Stat error: %v", err)
 		logger.Printf("Playing from %s; file on disk.", fileName)
 	}
 
-	streamer, format = getStreamer(started, fileName, logger)
+	streamer, format, err = getStreamer(started, fileName, logger)
+	if err != nil {
+		return nil, format, err
+	}
 
 	if done != nil {
 		go func() {
 			if result := <-done; result.IsSuccess() {
 				streamer.SetLength(int(result.Size))
 			}
+			streamer.Finish()
 			close(done)
 		}()
+	} else {
+		streamer.Finish()
 	}
 
-	return streamer, format
+	return streamer, format, nil
 }
 
-const maxNameLen = 64
+// AdoptCachedAudio renames audio cached under the url of its enclosure, as it was before
+// audio was cached under the id of its episode, so that it is played from disk rather
+// than downloaded again
+func AdoptCachedAudio(cachePath string, url string, id string) {
+	fileName := filepath.Join(cachePath, episodeFileName(id))
+	if _, err := os.Stat(fileName); err == nil {
+		return
+	}
+	// The cache path and the name were once joined without a separator
+	legacyNames := []string{filepath.Join(cachePath, episodeFileName(url)), cachePath + episodeFileName(url)}
+	for _, legacy := range legacyNames {
+		if info, err := os.Stat(legacy); err != nil || info.Size() == 0 {
+			continue
+		}
+		if err := os.Rename(legacy, fileName); err != nil {
+			loggers[TCPALog].Printf("Could not adopt %s as %s: %v", legacy, fileName, err)
+		}
+		return
+	}
+}
 
-// url2FileName deterministically creates a filename for the download based on the url
-func url2FileName(url string, logger *log.Logger) string {
+// episodeFileName deterministically creates a filename for the download based on the id
+// of the episode
+func episodeFileName(id string) string {
 	hash := sha1.New()
-	if _, err := io.WriteString(hash, url); err != nil {
-		logger.Fatalln(err)
-	}
+	_, _ = io.WriteString(hash, id)
 
 	filename := fmt.Sprintf("%x", hash.Sum(nil))
 	filename += ".mp3"
@@ -193,47 +243,88 @@ func url2FileName(url string, logger *log.Logger) string {
 	return filename
 }
 
+// partialName returns the name that the audio cached as fileName is downloaded to, it is
+// renamed to fileName once the download is complete
+func partialName(fileName string) string {
+	return fileName + ".part"
+}
+
 // getStreamer uses the filesystem path of the cached audio to create the Decoder. It mirrors the interface of
 // mp3.Decode from beep
-func getStreamer(started chan *SizedResult, filepath string, logger *log.Logger) (streamer *Decoder, format beep.Format) {
+func getStreamer(started chan *SizedResult, filepath string, logger *log.Logger) (streamer *Decoder, format beep.Format, err error) {
 
 	if started == nil {
 		logger.Print("channel is nil, attempting to play from disk")
 		audio, err := os.Open(filepath)
 		if err != nil {
-			logger.Fatalln(err)
-		}
-		streamer, format, err = boopDecode(audio, logger)
-		if err != nil {
-			logger.Fatalln(err)
+			return nil, format, err
 		}
-		return streamer, format
+		return boopDecode(audio, logger)
 	} else {
 		defer close(started)
 	}
 
-	if result := <-started; result.IsSuccess() {
-		audio, err := os.Open(filepath)
-		if err != nil {
-			logger.Fatalln(err)
-		}
-		streamer, format, err = boopDecode(audio, logger)
-		if err != nil {
-			logger.Fatalln(err)
-		}
-		streamer.SetLength(int(result.Size))
-	} else {
-		logger.Fatalln(result)
+	result := <-started
+	if !result.IsSuccess() {
+		return nil, format, result.Err
+	}
+
+	audio, err := os.Open(filepath)
+	if err != nil {
+		return nil, format, err
+	}
+	streamer, format, err = boopDecode(audio, logger)
+	if err != nil {
+		_ = audio.Close()
+		return nil, format, err
 	}
-	return streamer, format
+	streamer.SetLength(int(result.Size))
+
+	return streamer, format, nil
+}
+
+// ProgressFunc is called as audio is downloaded to the cache, with the id of the episode
+// it is the audio of. total is -1 if the size of the download is not known
+type ProgressFunc func(id, url string, complete, total int64)
+
+var progressFunc ProgressFunc = func(string, string, int64, int64) {}
+
+// SetProgressFunc should be called by the bootstrapping application to be told about
+// the progress of downloads
+func SetProgressFunc(f ProgressFunc) {
+	progressFunc = f
+}
+
+const progressInterval = time.Second / 2
+
+// progressWriter counts the bytes written through it, reporting them to the progressFunc
+// at most once per progressInterval
+type progressWriter struct {
+	io.Writer
+	id       string
+	url      string
+	complete int64
+	total    int64
+	reported time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.complete += int64(n)
+	if time.Since(w.reported) >= progressInterval {
+		w.reported = time.Now()
+		progressFunc(w.id, w.url, w.complete, w.total)
+	}
+	return n, err
 }
 
 // asyncDownloadAudio sets off a doDownload goroutine and returns the started and done channels that
 // it will report back its progress on.
-func asyncDownloadAudio(filename, url string, logger *log.Logger) (started chan *SizedResult, done chan *SizedResult) {
-	started, done = make(chan *SizedResult), make(chan *SizedResult)
+func asyncDownloadAudio(filename, url, id string, logger *log.Logger) (started chan *SizedResult, done chan *SizedResult) {
+	// done is buffered so that the downloader is not left blocking if nobody is waiting on it
+	started, done = make(chan *SizedResult), make(chan *SizedResult, 1)
 
-	go doDownload(filename, url, started, done)
+	go doDownload(filename, url, id, started, done)
 	logger.Printf("downloader started")
 
 	return started, done
@@ -241,21 +332,31 @@ func asyncDownloadAudio(filename, url string, logger *log.Logger) (started chan
 
 // doDownload will download the provided url to the filepath specified. Supply two chan error, started
 // will send nil on successful start, or an error. If started returns nil, then done will send another
-// nil if the file successfully downloaded and the error otherwise.
-func doDownload(filepath string, url string, started chan *SizedResult, done chan *SizedResult) {
+// nil if the file successfully downloaded and the error otherwise. The audio is written to the
+// partialName of filepath and only moved to filepath once it has all been downloaded, so that an
+// interrupted download is never mistaken for the whole episode.
+func doDownload(filepath string, url string, id string, started chan *SizedResult, done chan *SizedResult) {
 
 	var out *os.File
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := fetchAudio(url)
 	if err != nil {
 		started <- Fail(err)
 		return
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		started <- Fail(fmt.Errorf("status error: %v", resp.StatusCode))
+		return
+	}
+
 	// Create the file
-	out, err = os.Create(filepath)
+	partial := partialName(filepath)
+	out, err = os.Create(partial)
 	if err != nil {
+		_ = resp.Body.Close()
 		started <- Fail(err)
 		return
 	}
@@ -270,19 +371,25 @@ func doDownload(filepath string, url string, started chan *SizedResult, done cha
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
-	defer func(out *os.File) {
-		// might need to handle this if it's an issue
-		_ = out.Close()
-	}(out)
 
 	// Get to Copyin'
-	size, copyErr := io.Copy(out, resp.Body)
+	size, copyErr := io.Copy(&progressWriter{Writer: out, id: id, url: url, total: resp.ContentLength}, resp.Body)
+	progressFunc(id, url, size, resp.ContentLength)
+	if closeErr := out.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
 
 	// Send the result down the pipe
 	if copyErr != nil {
-		fmt.Println(copyErr)
+		loggers[DLLog].Printf("download of %s failed: %v", url, copyErr)
+		_ = os.Remove(partial)
 		done <- Fail(copyErr)
-	} else {
-		done <- Success(size)
+		return
+	}
+	// The episode has played from the partial file either way, it is downloaded again
+	// next time if it cannot be kept
+	if err = os.Rename(partial, filepath); err != nil {
+		loggers[DLLog].Printf("Could not keep the download of %s: %v", url, err)
 	}
+	done <- Success(size)
 }
@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptCachedAudio(t *testing.T) {
+	const url = "https://example.com/ep1.mp3"
+	cachePath := t.TempDir()
+	legacy := filepath.Join(cachePath, episodeFileName(url))
+	if err := os.WriteFile(legacy, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	AdoptCachedAudio(cachePath, url, "guid-1")
+
+	content, err := os.ReadFile(filepath.Join(cachePath, episodeFileName("guid-1")))
+	if err != nil {
+		t.Fatalf("the audio cached under its url was not adopted: %v", err)
+	}
+	if string(content) != "audio" {
+		t.Errorf("the adopted audio holds %q", content)
+	}
+	if _, err = os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("the audio is still cached under its url")
+	}
+}
+
+func TestInterruptedDownloadIsNotCached(t *testing.T) {
+	InitLoggers(func(prefix string) *log.Logger { return log.New(io.Discard, prefix, 0) })
+	forgetResolved()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		_, _ = io.WriteString(w, "audio")
+		if r.URL.Path == "/whole.mp3" {
+			_, _ = io.WriteString(w, "audio")
+		}
+	}))
+	defer server.Close()
+	download := func(path string) (string, *SizedResult) {
+		fileName := filepath.Join(t.TempDir(), episodeFileName(path))
+		started, done := make(chan *SizedResult, 1), make(chan *SizedResult, 1)
+		doDownload(fileName, server.URL+path, path, started, done)
+		return fileName, <-done
+	}
+
+	fileName, result := download("/cut-off.mp3")
+	if result.IsSuccess() {
+		t.Error("a download that was cut off succeeded")
+	}
+	for _, name := range []string{fileName, partialName(fileName)} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind by a download that was cut off", filepath.Base(name))
+		}
+	}
+
+	fileName, result = download("/whole.mp3")
+	if !result.IsSuccess() {
+		t.Fatal(result.Err)
+	}
+	if content, err := os.ReadFile(fileName); err != nil || string(content) != "audioaudio" {
+		t.Errorf("the download was cached as %q: %v", content, err)
+	}
+	if _, err := os.Stat(partialName(fileName)); !os.IsNotExist(err) {
+		t.Error("the partial file was left behind by a download that finished")
+	}
+}
@@ -20,9 +20,10 @@ type ChunkBufferStreamer struct {
 	totalSampleCount int
 	currentOffset    int
 	format beep.Format
+	err              error
 }
 
-func NewChunkBufferStreamer(url string) (*ChunkBufferStreamer, beep.Format) {
+func NewChunkBufferStreamer(url string) (*ChunkBufferStreamer, beep.Format, error) {
 	// Ought to make a request for the byte range corresponding
 	// to the beginning of the audio file to the chunk length
 	//
@@ -34,7 +35,7 @@ func NewChunkBufferStreamer(url string) (*ChunkBufferStreamer, beep.Format) {
 	cb := &ChunkBufferStreamer{}
 	cb.Episode = url
 	cb.next()
-	return cb, cb.format
+	return cb, cb.format, cb.err
 }
 
 // Stream, seek, close, pos, length
@@ -44,18 +45,26 @@ func (cb *ChunkBufferStreamer) Stream(samples [][2]float64) (int, bool) {
 		if cb.currentStreamer.currentStreamerExhausted() {
 			n := cb.next()
 			if n == nil {
-				return cb.currentStreamer.Stream(samples)
+				return cb.streamCurrent(samples)
 			}
 			cb.currentStreamer = n
 		}
 	} else {
 		n := cb.next()
 		if n == nil {
-			return cb.currentStreamer.Stream(samples)
+			return cb.streamCurrent(samples)
 		}
 		cb.currentStreamer = n
 	}
 
+	return cb.streamCurrent(samples)
+}
+
+// streamCurrent streams from the current chunk unless a chunk has failed to load
+func (cb *ChunkBufferStreamer) streamCurrent(samples [][2]float64) (int, bool) {
+	if cb.err != nil || cb.currentStreamer == nil {
+		return 0, false
+	}
 	return cb.currentStreamer.Stream(samples)
 }
 
@@ -68,6 +77,9 @@ func (cb *ChunkBufferStreamer) Seek(p int) error {
 		cb.nextStreamer = nil
 		cb.currentOffset = p
 		cb.currentStreamer = cb.next()
+		if cb.err != nil {
+			return cb.err
+		}
 		return cb.currentStreamer.Seek(0)
 	}
 
@@ -76,6 +88,9 @@ func (cb *ChunkBufferStreamer) Seek(p int) error {
 	
 	if afterNextStart && beforeNextEnd {
 		cb.currentStreamer = cb.next()
+		if cb.err != nil {
+			return cb.err
+		}
 		return cb.currentStreamer.Seek(p - cb.currentOffset)
 	}
 	
@@ -102,27 +117,58 @@ func (cb *ChunkBufferStreamer) Length() int {
 	return cb.totalSampleCount
 }
 
-func (cb *ChunkBufferStreamer) RequestChunkAtOffset(offset int) io.ReadCloser {
-	rc, _ := cb.RequestSampleRange(offset, offset+chunkSampleCount)
-	return rc
+// Err returns the first error encountered while requesting or decoding a chunk
+func (cb *ChunkBufferStreamer) Err() error {
+	return cb.err
+}
+
+func (cb *ChunkBufferStreamer) RequestChunkAtOffset(offset int) (io.ReadCloser, error) {
+	rc, _, err := cb.RequestSampleRange(offset, offset+chunkSampleCount)
+	return rc, err
 }
 
-func (cb *ChunkBufferStreamer) RequestSampleRange(start, end int) (io.ReadCloser, *http.Response) {
+func (cb *ChunkBufferStreamer) RequestSampleRange(start, end int) (io.ReadCloser, *http.Response, error) {
 	startByte := 4 * start
 	endByte := 4 * end
 
 	rangeHeader := fmt.Sprintf("bytes=%d-%d", startByte, endByte-1)
-	req, _ := http.NewRequest("GET", cb.Episode, nil)
+	req, err := NewRequest("GET", cb.Episode)
+	if err != nil {
+		return nil, nil, err
+	}
 	req.Header.Set("Range", rangeHeader)
-	resp, _ := http.DefaultClient.Do(req)
+	resp, err := newClient(0).Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GET error: %v", err)
+	}
 
 	if cb.totalSampleCount == 0 {
-		contentRange := resp.Header.Get("Content-Range")
-		totalByteLength, _ := strconv.Atoi(strings.Split(contentRange, "/")[1])
+		contentRange := strings.Split(resp.Header.Get("Content-Range"), "/")
+		if len(contentRange) != 2 {
+			_ = resp.Body.Close()
+			return nil, resp, fmt.Errorf("range error: no Content-Range in response (status %v)", resp.StatusCode)
+		}
+		totalByteLength, _ := strconv.Atoi(contentRange[1])
 		cb.totalSampleCount = totalByteLength / 4
 	}
 
-	return resp.Body, resp
+	return resp.Body, resp, nil
+}
+
+// decodeChunkAtOffset requests and decodes the chunk starting at offset, any error
+// is recorded on the ChunkBufferStreamer and a nil streamer is returned
+func (cb *ChunkBufferStreamer) decodeChunkAtOffset(offset int) (*ClientStreamer, beep.Format) {
+	rc, err := cb.RequestChunkAtOffset(offset)
+	if err != nil {
+		cb.err = err
+		return nil, beep.Format{}
+	}
+	streamer, format, err := StreamDecode(rc)
+	if err != nil {
+		cb.err = err
+		return nil, format
+	}
+	return streamer, format
 }
 
 func (cb *ChunkBufferStreamer) next() *ClientStreamer {
@@ -134,10 +180,10 @@ func (cb *ChunkBufferStreamer) next() *ClientStreamer {
 	if cb.currentStreamer == nil && cb.nextStreamer == nil {
 		// this is the first streamer starting at the offset in the current streamer
 		// and the second streamer starting at the offset in the next streamer.
-		streamer, format, _ = StreamDecode(cb.RequestChunkAtOffset(cb.currentOffset))
+		streamer, format = cb.decodeChunkAtOffset(cb.currentOffset)
 		cb.currentStreamer = streamer
 		
-		nextStreamer, format, _ = StreamDecode(cb.RequestChunkAtOffset(nextOffset))
+		nextStreamer, format = cb.decodeChunkAtOffset(nextOffset)
 		cb.nextStreamer = nextStreamer
 	}
 
@@ -147,7 +193,7 @@ func (cb *ChunkBufferStreamer) next() *ClientStreamer {
 		cb.currentStreamer = cb.nextStreamer
 
 		if nextOffset < cb.totalSampleCount {
-			streamer, format, _ = StreamDecode(cb.RequestChunkAtOffset(nextOffset))
+			streamer, format = cb.decodeChunkAtOffset(nextOffset)
 			cb.nextStreamer = streamer
 		} else {
 			cb.nextStreamer = nil
@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// isoLayouts are the ISO 8601 layouts of the dates in Atom and Dublin Core elements,
+// which some feeds also use for their pubDate
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// rfc822Layouts are the layouts of RFC 822 and RFC 1123 dates once ParseDate has
+// removed the day of the week and made the zone numeric
+var rfc822Layouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+}
+
+// zoneOffsets are the offsets of the named zones allowed by RFC 822 and others that
+// feeds use. time.Parse gives names it does not know an offset of zero.
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800", "HST": "-1000",
+	"BST": "+0100", "IST": "+0100", "WET": "+0000", "WEST": "+0100",
+	"CET": "+0100", "CEST": "+0200", "EET": "+0200", "EEST": "+0300",
+	"AEST": "+1000", "AEDT": "+1100", "ACST": "+0930", "ACDT": "+1030",
+	"AWST": "+0800", "NZST": "+1200", "NZDT": "+1300", "JST": "+0900",
+}
+
+// ParseDate parses the date of a feed or episode. RFC 822 dates are accepted with or
+// without the day of the week and seconds, with two or four digit years, full or
+// abbreviated month names and named or numeric zones, as are ISO 8601 dates. Dates
+// without a zone, or with a zone name that is not known, are taken to be UTC.
+func ParseDate(raw string) (time.Time, error) {
+	date := strings.Join(strings.Fields(raw), " ")
+	if date == "" {
+		return time.Time{}, fmt.Errorf("no date")
+	}
+
+	for _, layout := range isoLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed, nil
+		}
+	}
+
+	normalised := normaliseRFC822(date)
+	for _, layout := range rfc822Layouts {
+		if parsed, err := time.Parse(layout, normalised); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", raw)
+}
+
+// normaliseRFC822 rewrites the variations of RFC 822 dates seen in feeds to match one
+// of rfc822Layouts: the day of the week is dropped, months are abbreviated and the zone
+// is made numeric
+func normaliseRFC822(date string) string {
+	fields := strings.Fields(strings.ReplaceAll(date, ",", " "))
+	if len(fields) > 0 && isWord(fields[0]) && len(fields) > 1 && !isWord(fields[1]) {
+		// A day of the week, which is not always spelled as RFC 822 expects
+		fields = fields[1:]
+	}
+	if len(fields) > 1 && isWord(fields[1]) && len(fields[1]) > 3 {
+		fields[1] = fields[1][:3]
+	}
+
+	if len(fields) == 4 {
+		// No zone was given
+		return strings.Join(append(fields, "+0000"), " ")
+	}
+	if len(fields) == 5 {
+		zone := fields[4]
+		if offset, ok := zoneOffsets[strings.ToUpper(zone)]; ok {
+			zone = offset
+		} else if isWord(zone) {
+			// A date a few hours out is more use than none
+			zone = "+0000"
+		}
+		fields[4] = strings.Replace(zone, ":", "", 1)
+	}
+	return strings.Join(fields, " ")
+}
+
+// isWord reports whether the field is made only of letters
+func isWord(field string) bool {
+	for _, r := range field {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return field != ""
+}
+
+// parseDates sets the times of the channels and items of the feed from their dates,
+// logging those that cannot be parsed
+func (feed *RSSFeed) parseDates() {
+	for c := range feed.Channel {
+		channel := &feed.Channel[c]
+		if channel.PubDate != "" {
+			published, err := ParseDate(channel.PubDate)
+			if err != nil {
+				loggers[RSSLog].Printf("Bad pubDate of %s: %v", channel.Title, err)
+			}
+			channel.Published = published
+		}
+		for i := range channel.Item {
+			item := &channel.Item[i]
+			date := item.PubDate
+			if date == "" {
+				date = item.Date
+			}
+			if date == "" {
+				continue
+			}
+			published, err := ParseDate(date)
+			if err != nil {
+				loggers[RSSLog].Printf("Bad pubDate of %s: %v", item.Title, err)
+			}
+			item.Published = published
+		}
+	}
+}
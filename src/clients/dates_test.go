@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want time.Time
+	}{
+		{"RFC 822", "Tue, 05 Mar 24 14:30 +0000", utc(2024, time.March, 5, 14, 30, 0)},
+		{"RFC 822 without the day", "05 Mar 2024 14:30:15 +0000", utc(2024, time.March, 5, 14, 30, 15)},
+		{"RFC 1123", "Tue, 05 Mar 2024 14:30:15 GMT", utc(2024, time.March, 5, 14, 30, 15)},
+		{"RFC 1123 with a numeric zone", "Tue, 05 Mar 2024 09:30:15 -0500", utc(2024, time.March, 5, 14, 30, 15)},
+		{"numeric zone with a colon", "Tue, 05 Mar 2024 16:30:15 +02:00", utc(2024, time.March, 5, 14, 30, 15)},
+		{"full names", "Tuesday, 5 March 2024 14:30:15 UT", utc(2024, time.March, 5, 14, 30, 15)},
+		{"misspelled day", "Tues, 05 Mar 2024 14:30:15 GMT", utc(2024, time.March, 5, 14, 30, 15)},
+		{"extra whitespace", "  Tue, 05  Mar 2024\n14:30:15 GMT ", utc(2024, time.March, 5, 14, 30, 15)},
+		{"no zone", "Tue, 05 Mar 2024 14:30:15", utc(2024, time.March, 5, 14, 30, 15)},
+		{"EST", "Tue, 05 Mar 2024 09:30:15 EST", utc(2024, time.March, 5, 14, 30, 15)},
+		{"PDT", "Fri, 05 Jul 2024 07:30:15 PDT", utc(2024, time.July, 5, 14, 30, 15)},
+		{"lower case zone", "Tue, 05 Mar 2024 15:30:15 cet", utc(2024, time.March, 5, 14, 30, 15)},
+		{"AEDT", "Wed, 06 Mar 2024 01:30:15 AEDT", utc(2024, time.March, 5, 14, 30, 15)},
+		{"unknown zone", "Tue, 05 Mar 2024 14:30:15 XYZ", utc(2024, time.March, 5, 14, 30, 15)},
+		{"ISO 8601", "2024-03-05T14:30:15Z", utc(2024, time.March, 5, 14, 30, 15)},
+		{"ISO 8601 with an offset", "2024-03-05T16:30:15+02:00", utc(2024, time.March, 5, 14, 30, 15)},
+		{"ISO 8601 with fractional seconds", "2024-03-05T14:30:15.250Z", time.Date(2024, time.March, 5, 14, 30, 15, 250e6, time.UTC)},
+		{"ISO 8601 without seconds", "2024-03-05T14:30Z", utc(2024, time.March, 5, 14, 30, 0)},
+		{"ISO 8601 without a zone", "2024-03-05T14:30:15", utc(2024, time.March, 5, 14, 30, 15)},
+		{"ISO 8601 with a space", "2024-03-05 14:30:15", utc(2024, time.March, 5, 14, 30, 15)},
+		{"ISO 8601 date", "2024-03-05", utc(2024, time.March, 5, 0, 0, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseDate(test.raw)
+			if err != nil {
+				t.Fatalf("ParseDate(%q): %v", test.raw, err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("ParseDate(%q) = %v, expected %v", test.raw, got.UTC(), test.want)
+			}
+		})
+	}
+}
+
+func TestParseDateFails(t *testing.T) {
+	for _, raw := range []string{"", "   ", "yesterday", "Tue, 32 Mar 2024 14:30:15 GMT", "2024-13-05", "05/03/2024"} {
+		if got, err := ParseDate(raw); err == nil {
+			t.Errorf("ParseDate(%q) = %v, expected an error", raw, got)
+		}
+	}
+}
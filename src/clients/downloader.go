@@ -12,10 +12,11 @@ type DownloadClient struct {
 }
 
 func NewClient() *DownloadClient {
-	// create a client
+	// create a client with the transport of the configured client
 	client := grab.NewClient()
+	client.HTTPClient = newClient(0)
 
-	// set the User Agent header
+	// set the User Agent header, which the configured client replaces with its own
 	client.UserAgent = "Last Player On The Left"
 
 	return &DownloadClient{Client: client}
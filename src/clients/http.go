@@ -0,0 +1,65 @@
+package clients
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// httpClient is the client whose transport and redirect limit every request is made
+	// with, see SetHTTPClient
+	httpClient     = &http.Client{}
+	httpClientLock sync.RWMutex
+)
+
+// SetHTTPClient should be called by the bootstrapping application with the client made
+// from the config, see app.HTTP, before any requests are made. Until it is the defaults
+// of net/http are used.
+func SetHTTPClient(client *http.Client) {
+	httpClientLock.Lock()
+	defer httpClientLock.Unlock()
+	httpClient = client
+}
+
+// configuredClient returns the client set by SetHTTPClient
+func configuredClient() *http.Client {
+	httpClientLock.RLock()
+	defer httpClientLock.RUnlock()
+	return httpClient
+}
+
+// newClient returns a client with the transport of the configured client that refuses
+// to follow redirects round in a loop or to pass credentials on to other hosts, see
+// checkRedirect. A timeout of zero lets requests take as long as their reads keep
+// arriving.
+func newClient(timeout time.Duration) *http.Client {
+	configured := configuredClient()
+	return &http.Client{
+		Transport:     configured.Transport,
+		Jar:           configured.Jar,
+		Timeout:       timeout,
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// NewRequest returns a request for the url with any credentials registered for it
+func NewRequest(method, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if credentials := credentialsFor(url); credentials != nil {
+		credentials.apply(req)
+	}
+	return req, nil
+}
+
+// Fetch requests the url with GET, sending any credentials registered for it
+func Fetch(url string) (*http.Response, error) {
+	req, err := NewRequest(http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(0).Do(req)
+}
@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ID returns an identifier for the episode that stays the same when the feed is
+// reordered or its enclosure urls change. It is the guid the feed gives the episode,
+// qualified by the podcast:guid of the feed where there is one, as guids need only be
+// unique within their feed. Episodes without a guid are identified by their enclosure
+// url, without its tracking prefixes, scheme and tracking parameters, and failing that
+// by their title and date. Where the url redirects to is not used, as it can change
+// from one request to the next.
+func (i *Item) ID() string {
+	if guid := strings.TrimSpace(i.Guid); guid != "" {
+		if i.FeedGuid != "" {
+			return i.FeedGuid + "/" + guid
+		}
+		return guid
+	}
+	if enclosure := NormaliseUrl(StripTracking(i.Enclosure.Url)); enclosure != "" {
+		return enclosure
+	}
+	return strings.TrimSpace(i.Title) + " " + strings.TrimSpace(i.PubDate)
+}
+
+// trackingParams are the query parameters added to enclosure urls to track where a
+// download came from, which do not change the audio. Parameters starting utm_ are also
+// tracking parameters.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "mc_cid": true, "mc_eid": true,
+	"awcollectionid": true, "awepisodeid": true,
+}
+
+// NormaliseUrl reduces an enclosure url to the parts that identify the audio, so that
+// the same file served over http and https, or with query parameters added for
+// tracking, gives the same result. Other query parameters are kept, as some hosts serve
+// every episode from one path and pick the audio by its query.
+func NormaliseUrl(raw string) string {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	cleaned := path.Clean("/" + parsed.Path)
+	if cleaned == "/" {
+		cleaned = ""
+	}
+
+	query := parsed.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(name)
+		}
+	}
+	normalised := strings.ToLower(parsed.Host) + cleaned
+	if len(query) > 0 {
+		// Encode sorts the parameters, so their order does not matter
+		normalised += "?" + query.Encode()
+	}
+	return normalised
+}
+
+// linkGuids gives each item the podcast:guid of its channel, so that the item can
+// qualify its own guid with it
+func (feed *RSSFeed) linkGuids() {
+	for c := range feed.Channel {
+		channel := &feed.Channel[c]
+		guid := strings.TrimSpace(channel.PodcastGuid)
+		for i := range channel.Item {
+			channel.Item[i].FeedGuid = guid
+		}
+	}
+}
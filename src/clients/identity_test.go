@@ -0,0 +1,28 @@
+package clients
+
+import "testing"
+
+func TestNormaliseUrl(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://Example.com/audio/ep1.mp3", "example.com/audio/ep1.mp3"},
+		{"http://example.com//audio/./ep1.mp3", "example.com/audio/ep1.mp3"},
+		{"https://example.com/ep1.mp3?utm_source=rss&utm_medium=feed", "example.com/ep1.mp3"},
+		{"https://example.com/ep1.mp3?awCollectionId=1&awEpisodeId=2&fbclid=3", "example.com/ep1.mp3"},
+		{"https://example.com/dl.php?ep=1", "example.com/dl.php?ep=1"},
+		{"https://example.com/dl.php?utm_source=rss&ep=2", "example.com/dl.php?ep=2"},
+		{"https://example.com/dl.php?show=a&ep=2", "example.com/dl.php?ep=2&show=a"},
+		{"not a url", "not a url"},
+	}
+	for _, test := range tests {
+		if got := NormaliseUrl(test.raw); got != test.want {
+			t.Errorf("NormaliseUrl(%q) = %q, expected %q", test.raw, got, test.want)
+		}
+	}
+
+	if NormaliseUrl("https://example.com/dl.php?ep=1") == NormaliseUrl("https://example.com/dl.php?ep=2") {
+		t.Error("episodes picked by their query share an id")
+	}
+}
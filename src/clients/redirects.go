@@ -0,0 +1,131 @@
+package clients
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// trackingPrefix matches the prefixes that analytics services put in front of the url of
+// the audio, which they redirect to once the download is counted. Services that take an
+// id have it matched as the element of the path after their own.
+var trackingPrefix = regexp.MustCompile(`^(?i)(https?://)(` + strings.Join([]string{
+	`(www\.|dts\.)?podtrac\.com/(pts/)?redirect\.[a-z0-9]+/`,
+	`chtbl\.com/track/[^/]+/`,
+	`chrt\.fm/track/[^/]+/`,
+	`pdst\.fm/e/`,
+	`op3\.dev/e(,[^/]*)?/`,
+	`pfx\.vpixl\.com/[^/]+/`,
+	`mgln\.ai/e/[^/]+/`,
+	`arttrk\.com/p/[^/]+/`,
+	`verifi\.podscribe\.com/rss/p/`,
+	`clrtpod\.com/m/[^/]+/`,
+	`prfx\.byspotify\.com/e/`,
+	`pscrb\.fm/rss/p/`,
+}, "|") + `)+`)
+
+// StripTracking removes any tracking prefixes from the start of an enclosure url,
+// leaving the url that they redirect to
+func StripTracking(url string) string {
+	url = strings.TrimSpace(url)
+	for {
+		match := trackingPrefix.FindStringSubmatch(url)
+		if match == nil {
+			return url
+		}
+		// Some prefixes are followed by the whole url of the next, scheme and all
+		rest := url[len(match[0]):]
+		if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+			rest = match[1] + rest
+		}
+		url = rest
+	}
+}
+
+// resolvedFile is the file in the cache that the resolved urls are kept in, so that
+// they are remembered for as long as the audio they lead to
+const resolvedFile = "resolved.json"
+
+var (
+	// resolved maps the enclosure urls that have been downloaded to where they led
+	resolved     = map[string]string{}
+	resolvedPath string
+	resolvedLock sync.RWMutex
+)
+
+// LoadResolved reads the enclosure urls resolved by earlier downloads to the cache at
+// cachePath, and keeps those resolved from now on there too
+func LoadResolved(cachePath string) error {
+	resolvedLock.Lock()
+	defer resolvedLock.Unlock()
+	resolvedPath = filepath.Join(cachePath, resolvedFile)
+
+	content, err := os.ReadFile(resolvedPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, &resolved)
+}
+
+// lookupResolved returns where the url led when it was last downloaded
+func lookupResolved(url string) (string, bool) {
+	resolvedLock.RLock()
+	defer resolvedLock.RUnlock()
+	final, ok := resolved[url]
+	return final, ok
+}
+
+// rememberResolved records that the url led to final, saving the mapping to the cache
+// if one has been loaded. Any credentials of the url are shared with final if it is on
+// the same host.
+func rememberResolved(url, final string) {
+	shareCredentials(url, final)
+
+	resolvedLock.Lock()
+	defer resolvedLock.Unlock()
+	if previous, ok := resolved[url]; ok && previous == final {
+		return
+	}
+	loggers[RSSLog].Printf("Resolved %s to %s", url, final)
+	resolved[url] = final
+	if resolvedPath == "" {
+		return
+	}
+	content, err := json.MarshalIndent(resolved, "", "  ")
+	if err == nil {
+		err = os.WriteFile(resolvedPath, content, 0644)
+	}
+	if err != nil {
+		loggers[RSSLog].Printf("Could not save the resolved urls: %v", err)
+	}
+}
+
+// fetchAudio requests the enclosure url, going straight to where it led last time if it
+// has been downloaded before, so that a download restarted later skips any tracking
+// redirects. The url itself is requested if where it led no longer works, as signed
+// urls expire.
+func fetchAudio(url string) (*http.Response, error) {
+	if final, ok := lookupResolved(url); ok && final != url {
+		resp, err := Fetch(final)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		loggers[RSSLog].Printf("%s no longer leads to %s, requesting it again", url, final)
+	}
+
+	resp, err := Fetch(url)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		rememberResolved(url, resp.Request.URL.String())
+	}
+	return resp, err
+}
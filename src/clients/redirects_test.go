@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// forgetResolved clears the resolved urls and stops them being saved, as if the
+// application had just started
+func forgetResolved() {
+	resolvedLock.Lock()
+	defer resolvedLock.Unlock()
+	resolved = map[string]string{}
+	resolvedPath = ""
+}
+
+func TestFetchAudioRemembersWhereItLed(t *testing.T) {
+	InitLoggers(func(prefix string) *log.Logger { return log.New(io.Discard, prefix, 0) })
+	forgetResolved()
+	defer forgetResolved()
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	expired := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/track":
+			http.Redirect(w, r, "/signed/audio.mp3", http.StatusFound)
+		case expired:
+			http.Error(w, "expired", http.StatusForbidden)
+		default:
+			_, _ = io.WriteString(w, "audio")
+		}
+	}))
+	defer server.Close()
+	fetch := func() {
+		t.Helper()
+		resp, err := fetchAudio(server.URL + "/track")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+	}
+
+	cachePath := t.TempDir()
+	if err := LoadResolved(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	fetch()
+
+	// A later run finds where the url led in the cache and goes straight there
+	forgetResolved()
+	if err := LoadResolved(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	fetch()
+	mu.Lock()
+	if requests["/track"] != 1 {
+		t.Errorf("the tracking url was requested %d times, expected once", requests["/track"])
+	}
+	expired = true
+	mu.Unlock()
+
+	// Once where it led stops working the url itself is requested again
+	resp, err := fetchAudio(server.URL + "/track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if requests["/track"] != 2 {
+		t.Errorf("the tracking url was requested %d times after its target expired, expected twice", requests["/track"])
+	}
+}
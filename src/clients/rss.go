@@ -6,88 +6,272 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type FeedCache map[string]*RSSFeed
 
 var (
-	feedCache FeedCache = make(map[string]*RSSFeed)
+	feedCache     FeedCache = make(map[string]*RSSFeed)
+	feedCacheLock sync.RWMutex
 )
 
 type Enclosure struct {
-	Url    string `xml:"url,attr"`
-	Length int64  `xml:"length,attr"`
-	Type   string `xml:"type,attr"`
+	Url    string `xml:"url,attr" json:"url"`
+	Length int64  `xml:"length,attr" json:"length"`
+	Type   string `xml:"type,attr" json:"type"`
 }
 
 // Defining Structs to parse clients Feed xml from HTTP request.
 // The full feed including header.
 type RSSFeed struct {
-	XMLName xml.Name  `xml:"rss"`
-	Channel []Channel `xml:"channel"`
+	XMLName xml.Name  `xml:"rss" json:"-"`
+	Channel []Channel `xml:"channel" json:"channel"`
 }
 
-// Channel data represents an entire podcast.
+// Channel data represents an entire podcast. Published is parsed from PubDate and is
+// zero if the feed gives no date or one that could not be parsed. PodcastGuid is the
+// podcast:guid that identifies the podcast wherever its feed is hosted, and NewFeedUrl
+// is where the feed has moved to if it has been.
 type Channel struct {
-	XMLName     xml.Name `xml:"channel"`
-	Item        []Item   `xml:"item"`
-	Generator   string   `xml:"generator"`
-	Title       string   `xml:"title"`
-	Description string   `xml:"description"`
-	Language    string   `xml:"language"`
-	PubDate     string   `xml:"pubDate"`
+	XMLName     xml.Name  `xml:"channel" json:"-"`
+	Item        []Item    `xml:"item" json:"items"`
+	Generator   string    `xml:"generator" json:"generator"`
+	Title       string    `xml:"title" json:"title"`
+	Description string    `xml:"description" json:"description"`
+	Language    string    `xml:"language" json:"language"`
+	PubDate     string    `xml:"pubDate" json:"pubDate"`
+	Published   time.Time `xml:"-" json:"published"`
+	PodcastGuid string    `xml:"https://podcastindex.org/namespace/1.0 guid" json:"podcastGuid,omitempty"`
+	NewFeedUrl  string    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd new-feed-url" json:"newFeedUrl,omitempty"`
 }
 
-// Item contains data for individual episodes.
+// Item contains data for individual episodes. Content holds the full show notes where
+// the feed gives them separately from the description. Season and Episode are kept as
+// they appear in the feed, as they are not always numbers. Published is parsed from
+// PubDate, or the Dublin Core Date where there is no PubDate, and is zero if there is no
+// date that could be parsed. FeedGuid is the PodcastGuid of the channel, see ID.
 type Item struct {
-	XMLName     xml.Name  `xml:"item"`
-	Title       string    `xml:"title"`
-	Description string    `xml:"description"`
-	PubDate     string    `xml:"pubDate"`
-	Author      string    `xml:"author"`
-	Link        string    `xml:"link"`
-	Enclosure   Enclosure `xml:"enclosure"`
+	XMLName     xml.Name     `xml:"item" json:"-"`
+	Title       string       `xml:"title" json:"title"`
+	Guid        string       `xml:"guid" json:"guid,omitempty"`
+	FeedGuid    string       `xml:"-" json:"feedGuid,omitempty"`
+	Description string       `xml:"description" json:"description"`
+	Content     string       `xml:"http://purl.org/rss/1.0/modules/content/ encoded" json:"content,omitempty"`
+	PubDate     string       `xml:"pubDate" json:"pubDate"`
+	Date        string       `xml:"http://purl.org/dc/elements/1.1/ date" json:"date,omitempty"`
+	Published   time.Time    `xml:"-" json:"published"`
+	Author      string       `xml:"author" json:"author"`
+	Link        string       `xml:"link" json:"link"`
+	Enclosure   Enclosure    `xml:"enclosure" json:"enclosure"`
+	Duration    string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration" json:"duration,omitempty"`
+	Season      string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season" json:"season,omitempty"`
+	Episode     string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode" json:"episode,omitempty"`
+	Transcripts []Transcript `xml:"https://podcastindex.org/namespace/1.0 transcript" json:"transcripts,omitempty"`
+}
+
+// ShowNotes returns the fullest show notes the feed gives for the episode, as HTML
+func (i *Item) ShowNotes() string {
+	if strings.TrimSpace(i.Content) != "" {
+		return i.Content
+	}
+	return i.Description
+}
+
+// Length returns the duration given by itunes:duration, which is either a number of
+// seconds or hh:mm:ss, mm:ss. It reports false if the feed gives no usable duration.
+func (i *Item) Length() (time.Duration, bool) {
+	spec := strings.TrimSpace(i.Duration)
+	if spec == "" {
+		return 0, false
+	}
+	var seconds float64
+	for _, part := range strings.Split(spec, ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// ItemFromUrl creates an Item for audio that did not come from a feed. If no title is
+// supplied the last element of the url is used
+func ItemFromUrl(url, title string) *Item {
+	if title == "" {
+		title = path.Base(url)
+	}
+	return &Item{Title: title, Enclosure: Enclosure{Url: url}}
+}
+
+const (
+	// maxRedirects is the most redirects followed until SetHTTPClient is called
+	maxRedirects = 10
+	// maxFeedMoves is the most itunes:new-feed-url tags followed from one feed
+	maxFeedMoves = 5
+)
+
+// MoveFunc is called when a feed is found to have moved for good, from the url it was
+// requested at to the url it should be requested at from now on
+type MoveFunc func(from, to string)
+
+var moveFunc MoveFunc = func(string, string) {}
+
+// SetMoveFunc should be called by the bootstrapping application to be told about feeds
+// that have moved, so that their subscriptions can be updated
+func SetMoveFunc(f MoveFunc) {
+	moveFunc = f
 }
 
 // GetContent retrieves a clients Feed via HTTP Request.
 // Parse the xml in the response into structs.
-// Exit & Print in the event of an error.
+// Errors are returned rather than exiting so that the caller can report them.
+// A feed that has moved, by a permanent redirect or an itunes:new-feed-url tag, is
+// retrieved from where it has moved to and the MoveFunc is told about the move.
 func GetContent(url string) (*RSSFeed, error) {
-	loggers[RSSLog] = loggers[RSSLog]
 	loggers[RSSLog].Printf("Retrieving RSS Feed at: %s", url)
-	if feed, ok := feedCache[url]; !ok {
-		loggers[RSSLog].Print("Cache Miss")
-		feed = &RSSFeed{}
-		resp, err := http.Get(url)
-		if err != nil {
-			return nil, fmt.Errorf("GET error: %v", err)
+	feedCacheLock.RLock()
+	feed, ok := feedCache[url]
+	feedCacheLock.RUnlock()
+	if ok {
+		loggers[RSSLog].Print("Cache Hit")
+		return feed, nil
+	}
+
+	loggers[RSSLog].Print("Cache Miss")
+	feed, location, err := locateFeed(url)
+	if err != nil {
+		return nil, err
+	}
+
+	feedCacheLock.Lock()
+	feedCache[url] = feed
+	feedCache[location] = feed
+	feedCacheLock.Unlock()
+
+	if location != url {
+		loggers[RSSLog].Printf("Feed %s has moved to %s", url, location)
+		moveFunc(url, location)
+	}
+	return feed, nil
+}
+
+// locateFeed fetches the feed at url and any feeds its itunes:new-feed-url leads to,
+// returning the last of them and the url it was found at. A new feed that cannot be
+// fetched is logged and the feed that named it is returned instead, and new feeds that
+// lead round in a loop are ignored.
+func locateFeed(url string) (*RSSFeed, string, error) {
+	first, firstLocation, err := fetchFeed(url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	feed, location := first, firstLocation
+	visited := map[string]bool{url: true, location: true}
+	for moves := 0; moves < maxFeedMoves; moves++ {
+		next := strings.TrimSpace(feed.Channel[0].NewFeedUrl)
+		if next == "" || next == location {
+			break
 		}
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				loggers[RSSLog].Fatal(err)
-			}
-		}(resp.Body)
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("status error: %v", resp.StatusCode)
+		if visited[next] {
+			// Neither feed can be trusted to be the new one
+			loggers[RSSLog].Printf("Ignoring the new-feed-url of %s, it leads back to %s", location, next)
+			return first, firstLocation, nil
 		}
+		visited[next] = true
 
-		data, err := ioutil.ReadAll(resp.Body)
+		moved, movedLocation, err := fetchFeed(next)
 		if err != nil {
-			return nil, fmt.Errorf("read body: %v", err)
+			loggers[RSSLog].Printf("Could not follow the new-feed-url of %s: %v", location, err)
+			break
 		}
+		feed, location = moved, movedLocation
+		visited[location] = true
+	}
+	return feed, location, nil
+}
 
-		err = xml.Unmarshal(data, feed)
+// checkRedirect stops a request that has been redirected more times than the configured
+// client allows, or to a url it has been redirected from before, and keeps credentials
+// to the hosts they are for
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if limit := configuredClient().CheckRedirect; limit != nil {
+		if err := limit(req, via); err != nil {
+			return err
+		}
+	} else if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	for _, earlier := range via {
+		if earlier.URL.String() == req.URL.String() {
+			return fmt.Errorf("redirect loop at %s", req.URL)
+		}
+	}
+	redirectCredentials(req, via)
+	return nil
+}
+
+// permanentLocation returns the url that the response was finally served from if every
+// redirect on the way there was permanent, and otherwise the url that was requested.
+// Feeds that are temporarily redirected must still be requested at their own url.
+func permanentLocation(url string, resp *http.Response) string {
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		status := req.Response.StatusCode
+		if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+			return url
+		}
+	}
+	return resp.Request.URL.String()
+}
+
+// fetchFeed requests the feed at url and parses the response, returning it with the
+// url the feed has moved to if it was permanently redirected, or url if not
+func fetchFeed(url string) (*RSSFeed, string, error) {
+	feed := &RSSFeed{}
+	req, err := NewRequest(http.MethodGet, url)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := newClient(0).Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("GET error: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
 		if err != nil {
 			loggers[RSSLog].Printf("ERROR: %s", err.Error())
 		}
-		feedCache[url] = feed
-	} else {
-		loggers[RSSLog].Print("Cache Hit")
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status error: %v", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read body: %v", err)
+	}
+
+	err = xml.Unmarshal(data, feed)
+	if err != nil {
+		loggers[RSSLog].Printf("ERROR: %s", err.Error())
+		return nil, "", fmt.Errorf("parse feed: %v", err)
+	}
+	if len(feed.Channel) == 0 {
+		return nil, "", fmt.Errorf("parse feed: no channel in %s", url)
 	}
+	feed.parseDates()
+	feed.linkGuids()
+	feed.authoriseEnclosures(url)
 
-	return feedCache[url], nil
+	location := permanentLocation(url, resp)
+	shareCredentials(url, location)
+	return feed, location, nil
 }
 
 // EpisodeData iterates over Item structs within the Channel struct.
@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"sort"
+	"strings"
+)
+
+// Where a Hit matched the query
+const (
+	TitleMatch       = "title"
+	DescriptionMatch = "description"
+	TranscriptMatch  = "transcript"
+)
+
+// Hit is an episode of a cached feed that matched a search
+type Hit struct {
+	FeedUrl   string
+	FeedTitle string
+	Item      *Item
+	Match     string
+}
+
+// Search looks for the query, case-insensitively, in the titles and descriptions of
+// every episode of every feed in the cache, and in the transcripts that have been
+// fetched. Hits are grouped by feed, with each feed's hits in the order of the feed.
+func Search(query string) []Hit {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	feedCacheLock.RLock()
+	urls := make([]string, 0, len(feedCache))
+	for url := range feedCache {
+		urls = append(urls, url)
+	}
+	feeds := make(map[string]*RSSFeed, len(feedCache))
+	for url, feed := range feedCache {
+		feeds[url] = feed
+	}
+	feedCacheLock.RUnlock()
+
+	sort.Slice(urls, func(i, j int) bool {
+		return feeds[urls[i]].Channel[0].Title < feeds[urls[j]].Channel[0].Title
+	})
+
+	var hits []Hit
+	for _, url := range urls {
+		channel := &feeds[url].Channel[0]
+		for i := range channel.Item {
+			if match := matchItem(query, &channel.Item[i]); match != "" {
+				hits = append(hits, Hit{FeedUrl: url, FeedTitle: channel.Title, Item: &channel.Item[i], Match: match})
+			}
+		}
+	}
+	return hits
+}
+
+// UnfetchedTranscripts returns up to limit transcripts of the cached feeds that have
+// been neither fetched nor tried without success, those of the newest episodes first
+func UnfetchedTranscripts(limit int) []Transcript {
+	feedCacheLock.RLock()
+	var items []*Item
+	for _, feed := range feedCache {
+		channel := &feed.Channel[0]
+		for i := range channel.Item {
+			if len(channel.Item[i].Transcripts) > 0 {
+				items = append(items, &channel.Item[i])
+			}
+		}
+	}
+	feedCacheLock.RUnlock()
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.After(items[j].Published)
+	})
+
+	transcriptCacheLock.RLock()
+	defer transcriptCacheLock.RUnlock()
+	var missing []Transcript
+	seen := map[string]bool{}
+	for _, item := range items {
+		url := item.Transcripts[0].Url
+		if _, ok := transcriptCache[url]; ok || transcriptFailed[url] || seen[url] {
+			continue
+		}
+		seen[url] = true
+		missing = append(missing, item.Transcripts[0])
+		if len(missing) == limit {
+			break
+		}
+	}
+	return missing
+}
+
+// matchItem returns where the lower case query was found in the item, or an empty
+// string if it was not found
+func matchItem(query string, item *Item) string {
+	switch {
+	case strings.Contains(strings.ToLower(item.Title), query):
+		return TitleMatch
+	case strings.Contains(strings.ToLower(item.Description), query):
+		return DescriptionMatch
+	}
+	for _, transcript := range item.Transcripts {
+		if text, ok := CachedTranscript(transcript.Url); ok && strings.Contains(strings.ToLower(text), query) {
+			return TranscriptMatch
+		}
+	}
+	return ""
+}
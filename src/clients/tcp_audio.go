@@ -18,7 +18,7 @@ func StreamDecode(audio io.ReadCloser) (*ClientStreamer, beep.Format, error) {
 	)
 	streamer, format, err = mp3.Decode(audio)
 	if err != nil {
-		panic(err)
+		return nil, format, err
 	}
 	buff := beep.NewBuffer(format)
 
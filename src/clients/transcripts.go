@@ -0,0 +1,108 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Transcript is a podcast:transcript element, linking to the transcript of an episode
+type Transcript struct {
+	Url      string `xml:"url,attr" json:"url"`
+	Type     string `xml:"type,attr" json:"type"`
+	Language string `xml:"language,attr" json:"language,omitempty"`
+}
+
+// transcriptCache holds the text of the transcripts fetched so far, keyed by url, and
+// transcriptFailed the urls of those that could not be fetched, which are not retried
+// by UnfetchedTranscripts
+var (
+	transcriptCache     = map[string]string{}
+	transcriptFailed    = map[string]bool{}
+	transcriptCacheLock sync.RWMutex
+)
+
+var (
+	// voice matches the VTT tags naming the speaker of a cue, which are kept so that
+	// speakers can be searched for
+	voice = regexp.MustCompile(`<v(?:\.[^ >]*)? ([^>]*)>`)
+	// markup matches the tags of HTML transcripts and the cue timings of VTT and SRT ones
+	markup = regexp.MustCompile(`<[^>]*>|\d*:?\d+:\d+[.,]\d+ --> \d*:?\d+:\d+[.,]\d+.*`)
+)
+
+// CachedTranscript returns the text of the transcript at url if it has been fetched
+func CachedTranscript(url string) (string, bool) {
+	transcriptCacheLock.RLock()
+	defer transcriptCacheLock.RUnlock()
+	text, ok := transcriptCache[url]
+	return text, ok
+}
+
+// GetTranscript returns the plain text of the transcript, fetching it if it has not
+// been fetched already
+func GetTranscript(transcript Transcript) (string, error) {
+	if text, ok := CachedTranscript(transcript.Url); ok {
+		return text, nil
+	}
+
+	text, err := fetchTranscript(transcript)
+	transcriptCacheLock.Lock()
+	defer transcriptCacheLock.Unlock()
+	if err != nil {
+		transcriptFailed[transcript.Url] = true
+		return "", err
+	}
+	transcriptCache[transcript.Url] = text
+	delete(transcriptFailed, transcript.Url)
+	return text, nil
+}
+
+// fetchTranscript fetches the transcript and extracts its plain text
+func fetchTranscript(transcript Transcript) (string, error) {
+	loggers[RSSLog].Printf("Retrieving transcript at: %s", transcript.Url)
+	resp, err := Fetch(transcript.Url)
+	if err != nil {
+		return "", fmt.Errorf("GET error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status error: %v", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read body: %v", err)
+	}
+
+	text, err := transcriptText(transcript.Type, data)
+	if err != nil {
+		return "", fmt.Errorf("parse transcript: %v", err)
+	}
+	return text, nil
+}
+
+// transcriptText extracts the spoken text from a transcript in any of the formats
+// allowed by the podcast namespace
+func transcriptText(mimeType string, data []byte) (string, error) {
+	if strings.Contains(mimeType, "json") {
+		var doc struct {
+			Segments []struct {
+				Body string `json:"body"`
+			} `json:"segments"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", err
+		}
+		bodies := make([]string, len(doc.Segments))
+		for i, segment := range doc.Segments {
+			bodies[i] = segment.Body
+		}
+		return strings.Join(bodies, " "), nil
+	}
+
+	text := voice.ReplaceAllString(string(data), "$1: ")
+	return markup.ReplaceAllString(text, " "), nil
+}
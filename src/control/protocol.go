@@ -0,0 +1,50 @@
+package control
+
+import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"os"
+	"path/filepath"
+)
+
+// The commands understood by the control socket
+const (
+	Play    = "play"
+	Pause   = "pause"
+	Toggle  = "toggle"
+	Seek    = "seek"
+	Skip    = "skip"
+	Volume  = "volume"
+	Speed   = "speed"
+	Enqueue = "enqueue"
+	State   = "state"
+)
+
+// Request is a single command sent to the control socket, encoded as one line of JSON
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response is the reply to every Request, it always carries the player state after the
+// command has been carried out
+type Response struct {
+	Ok      bool                   `json:"ok"`
+	Error   string                 `json:"error,omitempty"`
+	State   audiopanel.PlayerState `json:"state"`
+	Episode *clients.Item          `json:"episode,omitempty"`
+	Queue   []*clients.Item        `json:"queue,omitempty"`
+}
+
+// SocketPath returns the path of the control socket. It lives under $XDG_RUNTIME_DIR
+// when that is set and in a per user directory under the temp dir otherwise
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("last_player-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "last_player")
+	}
+	return filepath.Join(dir, "control.sock")
+}
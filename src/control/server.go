@@ -0,0 +1,168 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"log"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Server accepts connections on the control socket and carries out the commands sent
+// down them against the AudioPanel
+type Server struct {
+	listener net.Listener
+	panel    *audiopanel.AudioPanel
+	logger   *log.Logger
+	path     string
+}
+
+// Listen creates the control socket at socketPath. A stale socket left behind by a
+// previous instance is replaced, but an error is returned if another instance is
+// still listening on it
+func Listen(socketPath string, panel *audiopanel.AudioPanel, logger *log.Logger) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("another player is listening on %s", socketPath)
+	}
+	_ = os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{listener: listener, panel: panel, logger: logger, path: socketPath}, nil
+}
+
+// Serve accepts connections until the Server is closed, each connection is handled on
+// its own goroutine
+func (s *Server) Serve() {
+	s.logger.Printf("Listening on %s", s.path)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.logger.Printf("Stopped listening: %v", err)
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops the Server and removes the socket
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	_ = os.Remove(s.path)
+	return err
+}
+
+// handleConn reads requests from the connection, one per line, and writes a Response
+// for each of them
+func (s *Server) handleConn(conn net.Conn) {
+	defer func(conn net.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		var resp *Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = Respond(s.panel, fmt.Errorf("bad request: %v", err))
+		} else {
+			s.logger.Printf("Received %+v", req)
+			resp = Respond(s.panel, Execute(s.panel, req))
+		}
+		if err := encoder.Encode(resp); err != nil {
+			s.logger.Printf("Could not write response: %v", err)
+			return
+		}
+	}
+}
+
+// Respond builds the Response describing the outcome of a command
+func Respond(panel *audiopanel.AudioPanel, err error) *Response {
+	resp := &Response{
+		Ok:      err == nil,
+		State:   panel.GetPlayerState(),
+		Episode: panel.Playing(),
+		Queue:   panel.Queue(),
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// Execute carries out the command in the request against the panel
+func Execute(panel *audiopanel.AudioPanel, req Request) error {
+	switch req.Command {
+	case Play:
+		panel.SetPaused(false)
+	case Pause:
+		panel.SetPaused(true)
+	case Toggle:
+		panel.PlayPause()
+	case Skip:
+		return panel.Skip()
+	case State:
+	case Seek:
+		if len(req.Args) != 1 {
+			return fmt.Errorf("usage: seek POSITION")
+		}
+		position, err := audiopanel.ParsePosition(req.Args[0], panel.GetPlayerState().Position)
+		if err != nil {
+			return err
+		}
+		return panel.Seek(position)
+	case Volume:
+		gain, err := floatArg(req, "usage: volume GAIN")
+		if err != nil {
+			return err
+		}
+		return panel.SetVolume(gain)
+	case Speed:
+		speed, err := floatArg(req, "usage: speed RATIO")
+		if err != nil {
+			return err
+		}
+		return panel.SetSpeed(speed)
+	case Enqueue:
+		if len(req.Args) < 1 || len(req.Args) > 2 {
+			return fmt.Errorf("usage: enqueue URL [TITLE]")
+		}
+		title := ""
+		if len(req.Args) == 2 {
+			title = req.Args[1]
+		}
+		return panel.Enqueue(clients.ItemFromUrl(req.Args[0], title))
+	default:
+		return fmt.Errorf("unknown command %q", req.Command)
+	}
+
+	return nil
+}
+
+// floatArg parses the single numeric argument of a request, refusing NaN and infinities
+func floatArg(req Request, usage string) (float64, error) {
+	if len(req.Args) != 1 {
+		return 0, errors.New(usage)
+	}
+	value, err := strconv.ParseFloat(req.Args[0], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%s is not a number, %s", req.Args[0], usage)
+	}
+	return value, nil
+}
@@ -0,0 +1,171 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// listen serves the control socket in a temp dir, returning its path
+func listen(t *testing.T) string {
+	t.Helper()
+	discard := func(prefix string) *log.Logger { return log.New(io.Discard, prefix, 0) }
+	clients.InitLoggers(discard)
+	panel := audiopanel.FetchAudioPanel().
+		AttachLogger(discard("")).
+		AttachOutput(audiopanel.NewNullOutput(0)).
+		SetCachePath(t.TempDir())
+
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	server, err := Listen(socketPath, panel, discard(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { _ = server.Close() })
+	return socketPath
+}
+
+func TestCommands(t *testing.T) {
+	socketPath := listen(t)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	tests := []struct {
+		name    string
+		request Request
+		// err is a part of the error expected in the reply, none is expected if empty
+		err string
+	}{
+		{"state", Request{Command: State}, ""},
+		{"pause", Request{Command: Pause}, ""},
+		{"play", Request{Command: Play}, ""},
+		{"toggle", Request{Command: Toggle}, ""},
+		{"volume", Request{Command: Volume, Args: []string{"0.5"}}, ""},
+		{"volume without a gain", Request{Command: Volume}, "usage: volume GAIN"},
+		{"volume of two gains", Request{Command: Volume, Args: []string{"0.5", "1"}}, "usage: volume GAIN"},
+		{"volume of a word", Request{Command: Volume, Args: []string{"loud"}}, "loud is not a number"},
+		{"volume of NaN", Request{Command: Volume, Args: []string{"NaN"}}, "NaN is not a number"},
+		{"volume of infinity", Request{Command: Volume, Args: []string{"Inf"}}, "Inf is not a number"},
+		{"speed", Request{Command: Speed, Args: []string{"1.5"}}, ""},
+		{"speed of NaN", Request{Command: Speed, Args: []string{"nan"}}, "nan is not a number"},
+		{"speed of minus infinity", Request{Command: Speed, Args: []string{"-Inf"}}, "-Inf is not a number"},
+		{"speed of zero", Request{Command: Speed, Args: []string{"0"}}, "speed must be a positive number"},
+		{"seek without a position", Request{Command: Seek}, "usage: seek POSITION"},
+		{"seek to nonsense", Request{Command: Seek, Args: []string{"soon"}}, "soon"},
+		{"seek with nothing playing", Request{Command: Seek, Args: []string{"+30"}}, audiopanel.ErrNothingPlaying.Error()},
+		{"skip an empty queue", Request{Command: Skip}, audiopanel.ErrQueueEmpty.Error()},
+		{"enqueue without a url", Request{Command: Enqueue}, "usage: enqueue URL [TITLE]"},
+		{"enqueue missing audio", Request{Command: Enqueue, Args: []string{missing.URL + "/missing.mp3", "Missing"}}, "status error: 404"},
+		{"unknown", Request{Command: "rewind"}, `unknown command "rewind"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := Send(socketPath, test.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.err == "" {
+				if !resp.Ok || resp.Error != "" {
+					t.Errorf("replied with the error %q", resp.Error)
+				}
+				return
+			}
+			if resp.Ok || !strings.Contains(resp.Error, test.err) {
+				t.Errorf("replied ok=%v with the error %q, expected %q", resp.Ok, resp.Error, test.err)
+			}
+		})
+	}
+}
+
+func TestStateQueries(t *testing.T) {
+	socketPath := listen(t)
+
+	if _, err := Send(socketPath, Request{Command: Volume, Args: []string{"0.25"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Send(socketPath, Request{Command: Speed, Args: []string{"10"}}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Send(socketPath, Request{Command: State})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.State.Volume != 0.25 {
+		t.Errorf("the state has a volume of %v, expected 0.25", resp.State.Volume)
+	}
+	if resp.State.Speed != audiopanel.MaxSpeed {
+		t.Errorf("the state has a speed of %v, expected it clamped to %v", resp.State.Speed, audiopanel.MaxSpeed)
+	}
+	if resp.Episode != nil || len(resp.Queue) != 0 {
+		t.Errorf("the state has %v playing and %v queued with nothing enqueued", resp.Episode, resp.Queue)
+	}
+}
+
+func TestRequestsOnOneConnection(t *testing.T) {
+	socketPath := listen(t)
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	exchange := func(line string) Response {
+		t.Helper()
+		if _, err := io.WriteString(conn, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp Response
+		if err = json.Unmarshal(reply, &resp); err != nil {
+			t.Fatalf("the reply %s is not a Response: %v", reply, err)
+		}
+		return resp
+	}
+
+	if resp := exchange("toggle"); resp.Ok || !strings.Contains(resp.Error, "bad request") {
+		t.Errorf("a line that is not JSON was replied to with ok=%v and %q", resp.Ok, resp.Error)
+	}
+	// The connection is still served after a bad request
+	if resp := exchange(`{"command": "state"}`); !resp.Ok {
+		t.Errorf("a state query after a bad request failed: %s", resp.Error)
+	}
+	if resp := exchange(`{"command": "seek", "args": ["1:00"]}`); resp.Ok {
+		t.Error("seeking with nothing playing succeeded")
+	}
+}
+
+func TestListen(t *testing.T) {
+	socketPath := listen(t)
+	discard := log.New(io.Discard, "", 0)
+
+	if _, err := Listen(socketPath, audiopanel.FetchAudioPanel(), discard); err == nil {
+		t.Error("a second player listened on a socket that is in use")
+	}
+
+	// A socket left behind by a player that has gone is replaced
+	stale := filepath.Join(t.TempDir(), "control.sock")
+	listener, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = listener.Close()
+	server, err := Listen(stale, audiopanel.FetchAudioPanel(), discard)
+	if err != nil {
+		t.Fatalf("a stale socket was not replaced: %v", err)
+	}
+	_ = server.Close()
+}
@@ -0,0 +1,88 @@
+package domain
+
+import "sync"
+
+// Handler is called with each Event published to the Topic it is subscribed to
+type Handler func(event Event)
+
+// Subscription identifies a subscribed Handler so that it can be unsubscribed
+type Subscription struct {
+	topic Topic
+	id    int
+}
+
+// Bus fans published events out to the handlers subscribed to their Topic. It is safe
+// for use from multiple goroutines. Handlers are called on the publishing goroutine so
+// they should hand off anything slow, or anything that must happen on the UI thread.
+// They are called in the order they subscribed in, and see the events published by any
+// one goroutine in the order they were published.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Topic][]subscribed
+	nextId   int
+}
+
+// subscribed is a handler along with the id of its Subscription
+type subscribed struct {
+	id      int
+	handler Handler
+}
+
+// NewBus initialises an empty Bus
+func NewBus() *Bus {
+	return &Bus{handlers: map[Topic][]subscribed{}}
+}
+
+// Subscribe registers the handler to be called for every Event published to topic
+func (b *Bus) Subscribe(topic Topic, handler Handler) Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextId++
+	b.handlers[topic] = append(b.handlers[topic], subscribed{id: b.nextId, handler: handler})
+
+	return Subscription{topic: topic, id: b.nextId}
+}
+
+// Unsubscribe stops the handler identified by sub from receiving any further events
+func (b *Bus) Unsubscribe(sub Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	handlers := b.handlers[sub.topic]
+	for i, s := range handlers {
+		if s.id == sub.id {
+			// A new slice, as Publish may still be calling the handlers of the old one
+			b.handlers[sub.topic] = append(handlers[:i:i], handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish calls every handler subscribed to the topic of the event
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Topic()]
+	b.mu.RUnlock()
+
+	for _, s := range handlers {
+		s.handler(event)
+	}
+}
+
+var bus = NewBus()
+
+// Subscribe registers the handler with the application wide Bus
+func Subscribe(topic Topic, handler Handler) Subscription {
+	return bus.Subscribe(topic, handler)
+}
+
+// Unsubscribe removes the handler from the application wide Bus
+func Unsubscribe(sub Subscription) {
+	bus.Unsubscribe(sub)
+}
+
+// Publish sends the event to the handlers subscribed on the application wide Bus
+func Publish(event Event) {
+	bus.Publish(event)
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandlersAreCalledInOrder(t *testing.T) {
+	bus := NewBus()
+	var calls []int
+	var subs []Subscription
+	for i := 0; i < 10; i++ {
+		i := i
+		subs = append(subs, bus.Subscribe(PositionTickTopic, func(event Event) {
+			calls = append(calls, i)
+		}))
+	}
+	bus.Unsubscribe(subs[3])
+	bus.Unsubscribe(subs[7])
+	// Unsubscribing twice, or from a topic never published to, changes nothing
+	bus.Unsubscribe(subs[3])
+	bus.Unsubscribe(Subscription{topic: FeedMovedTopic, id: subs[0].id})
+
+	bus.Publish(PositionTick{})
+	expected := []int{0, 1, 2, 4, 5, 6, 8, 9}
+	if len(calls) != len(expected) {
+		t.Fatalf("the handlers were called as %v, expected %v", calls, expected)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("the handlers were called as %v, expected %v", calls, expected)
+		}
+	}
+}
+
+func TestEventsArriveInTheOrderPublished(t *testing.T) {
+	bus := NewBus()
+	var mu sync.Mutex
+	received := map[string][]int{}
+	bus.Subscribe(DownloadProgressTopic, func(event Event) {
+		progress := event.(DownloadProgress)
+		mu.Lock()
+		defer mu.Unlock()
+		received[progress.Episode] = append(received[progress.Episode], int(progress.Complete))
+	})
+
+	// Two publishers at once, the events of each arrive in the order it published them
+	var publishers sync.WaitGroup
+	for _, episode := range []string{"first", "second"} {
+		publishers.Add(1)
+		go func(episode string) {
+			defer publishers.Done()
+			for i := 0; i < 1000; i++ {
+				bus.Publish(DownloadProgress{Episode: episode, Complete: int64(i)})
+			}
+		}(episode)
+	}
+	publishers.Wait()
+
+	if len(received) != 2 {
+		t.Fatalf("received events from %d publishers, expected 2", len(received))
+	}
+	for episode, completes := range received {
+		if len(completes) != 1000 {
+			t.Fatalf("received %d events from the %s publisher of 1000", len(completes), episode)
+		}
+		for i, complete := range completes {
+			if complete != i {
+				t.Fatalf("event %d from the %s publisher arrived as %d", i, episode, complete)
+			}
+		}
+	}
+}
+
+func TestConcurrentSubscribeUnsubscribePublish(t *testing.T) {
+	bus := NewBus()
+	var kept int64
+	bus.Subscribe(FeedRefreshedTopic, func(event Event) { atomic.AddInt64(&kept, 1) })
+
+	var workers sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := 0; i < 200; i++ {
+				sub := bus.Subscribe(FeedRefreshedTopic, func(event Event) {})
+				bus.Publish(FeedRefreshed{})
+				bus.Unsubscribe(sub)
+			}
+		}()
+	}
+	workers.Wait()
+
+	if calls := atomic.LoadInt64(&kept); calls != 8*200 {
+		t.Errorf("a handler subscribed throughout was called %d times for %d events", calls, 8*200)
+	}
+	var after int64
+	bus.Subscribe(FeedRefreshedTopic, func(event Event) { atomic.AddInt64(&after, 1) })
+	bus.Publish(FeedRefreshed{})
+	bus.mu.RLock()
+	subscribed := len(bus.handlers[FeedRefreshedTopic])
+	bus.mu.RUnlock()
+	if subscribed != 2 || atomic.LoadInt64(&after) != 1 {
+		t.Errorf("%d handlers remain subscribed once the workers unsubscribed, expected 2", subscribed)
+	}
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"time"
+)
+
+// Topic identifies the kind of an Event, handlers are subscribed to a Topic
+type Topic int
+
+const (
+	FeedSelectedTopic Topic = iota
+	FeedRefreshedTopic
+	EpisodeStartedTopic
+	PlaybackPausedTopic
+	PositionTickTopic
+	DownloadProgressTopic
+	FeedMovedTopic
+	EpisodeFinishedTopic
+)
+
+// String returns the name of the Topic, which matches the name of its Event type
+func (t Topic) String() string {
+	return map[Topic]string{
+		FeedSelectedTopic:     "FeedSelected",
+		FeedRefreshedTopic:    "FeedRefreshed",
+		EpisodeStartedTopic:   "EpisodeStarted",
+		PlaybackPausedTopic:   "PlaybackPaused",
+		PositionTickTopic:     "PositionTick",
+		DownloadProgressTopic: "DownloadProgress",
+		FeedMovedTopic:        "FeedMoved",
+		EpisodeFinishedTopic:  "EpisodeFinished",
+	}[t]
+}
+
+// Event is implemented by everything that can be published on the Bus
+type Event interface {
+	Topic() Topic
+}
+
+// FeedSelected is published when the user picks a feed from the feeds menu
+type FeedSelected struct {
+	Index int              `json:"index"`
+	Feed  *clients.RSSFeed `json:"-"`
+}
+
+func (FeedSelected) Topic() Topic { return FeedSelectedTopic }
+
+// FeedRefreshed is published when a feed has been retrieved from its url
+type FeedRefreshed struct {
+	Url  string           `json:"url"`
+	Feed *clients.RSSFeed `json:"-"`
+}
+
+func (FeedRefreshed) Topic() Topic { return FeedRefreshedTopic }
+
+// EpisodeStarted is published when playback of an episode begins
+type EpisodeStarted struct {
+	Episode *clients.Item `json:"episode"`
+}
+
+func (EpisodeStarted) Topic() Topic { return EpisodeStartedTopic }
+
+// PlaybackPaused is published when playback is paused or resumed
+type PlaybackPaused struct {
+	Paused bool `json:"paused"`
+}
+
+func (PlaybackPaused) Topic() Topic { return PlaybackPausedTopic }
+
+// PositionTick is published periodically with the position of the playing episode
+type PositionTick struct {
+	Position time.Duration `json:"position"`
+	Length   time.Duration `json:"length"`
+	Playing  bool          `json:"playing"`
+	Speed    float64       `json:"speed"`
+}
+
+func (PositionTick) Topic() Topic { return PositionTickTopic }
+
+// DownloadProgress is published as an episode is downloaded to the cache. Episode is
+// the id of the episode, see clients.Item.ID. Total is -1 if the size of the download
+// is not known
+type DownloadProgress struct {
+	Episode  string `json:"episode"`
+	Url      string `json:"url"`
+	Complete int64  `json:"complete"`
+	Total    int64  `json:"total"`
+}
+
+func (DownloadProgress) Topic() Topic { return DownloadProgressTopic }
+
+// FeedMoved is published when a feed is found to have moved for good, by a permanent
+// redirect or an itunes:new-feed-url tag, from the url From to the url To
+type FeedMoved struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (FeedMoved) Topic() Topic { return FeedMovedTopic }
+
+// EpisodeFinished is published when playback reaches the end of an episode, before the
+// queue moves on to the next one
+type EpisodeFinished struct {
+	Episode *clients.Item `json:"episode"`
+}
+
+func (EpisodeFinished) Topic() Topic { return EpisodeFinishedTopic }
@@ -5,7 +5,8 @@ import (
 )
 
 // State represents all the shared global application state that is not managed by the
-// audiopanel
+// audiopanel. Changes to it are announced to the rest of the application by publishing
+// an Event
 type State struct {
 	FeedIndex      int
 	Feed           *clients.RSSFeed
@@ -26,25 +27,3 @@ func (s *State) Init() *State {
 
 // NoItem is used to indicate a "none" value for a menu index (i.e. a positive integer)
 const NoItem int = -1
-
-var receivers []Receiver
-
-// Receiver is the interface that must be implemented to be eligible for state change
-// notification
-type Receiver interface {
-	Receive(s State)
-}
-
-// Register is used to connect a controller up for state update notifications
-func Register(controller Receiver) Receiver {
-	receivers = append(receivers, controller)
-	return controller
-}
-
-// Notify will prompt all controllers to check if they need to update and if so, they will queue
-// an update
-func Notify(state State) {
-	for _, receiver := range receivers {
-		receiver.Receive(state)
-	}
-}
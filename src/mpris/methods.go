@@ -0,0 +1,90 @@
+package mpris
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"time"
+)
+
+// root implements the methods of org.mpris.MediaPlayer2. The player has no window to
+// raise and is quit from the terminal, so both are no-ops as permitted by CanRaise and
+// CanQuit
+type root struct{}
+
+func (root) Raise() *dbus.Error {
+	return nil
+}
+
+func (root) Quit() *dbus.Error {
+	return nil
+}
+
+// methods implements the methods of org.mpris.MediaPlayer2.Player. They are kept off
+// Player so that only the D-Bus methods are exported on the bus
+type methods struct {
+	p *Player
+}
+
+// Next skips to the next episode in the queue, there is no effect if it is empty
+func (m methods) Next() *dbus.Error {
+	if err := m.p.panel.Skip(); err != nil && err != audiopanel.ErrQueueEmpty {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// Previous restarts the current episode, the player keeps no history to go back to
+func (m methods) Previous() *dbus.Error {
+	return m.p.seekTo(0)
+}
+
+func (m methods) Pause() *dbus.Error {
+	m.p.panel.SetPaused(true)
+	return nil
+}
+
+func (m methods) PlayPause() *dbus.Error {
+	m.p.panel.PlayPause()
+	return nil
+}
+
+// Stop pauses and returns to the start of the episode
+func (m methods) Stop() *dbus.Error {
+	m.p.panel.SetPaused(true)
+	if err := m.p.panel.Seek(0); err != nil && err != audiopanel.ErrNothingPlaying {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (m methods) Play() *dbus.Error {
+	m.p.panel.SetPaused(false)
+	return nil
+}
+
+// SeekBy moves the position by offset microseconds, it is exported on the bus as Seek
+// but is renamed here to keep it apart from io.Seeker
+func (m methods) SeekBy(offset int64) *dbus.Error {
+	position := m.p.panel.GetPlayerState().Position + time.Duration(offset)*time.Microsecond
+	return m.p.seekTo(position)
+}
+
+// SetPosition moves to position microseconds, provided trackId is still playing and the
+// position is within the episode
+func (m methods) SetPosition(trackId dbus.ObjectPath, position int64) *dbus.Error {
+	state := m.p.panel.GetPlayerState()
+	target := time.Duration(position) * time.Microsecond
+	if trackId != trackIdOf(m.p.panel.Playing()) || target < 0 || target > state.Length {
+		return nil
+	}
+	return m.p.seekTo(target)
+}
+
+// OpenUri queues the audio at uri, it starts playing straight away if nothing is playing
+func (m methods) OpenUri(uri string) *dbus.Error {
+	if err := m.p.panel.Enqueue(clients.ItemFromUrl(uri, "")); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
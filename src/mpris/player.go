@@ -0,0 +1,277 @@
+package mpris
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	busName     = "org.mpris.MediaPlayer2.last_player"
+	objectPath  = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	rootIface   = "org.mpris.MediaPlayer2"
+	playerIface = "org.mpris.MediaPlayer2.Player"
+	noTrack     = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+)
+
+// The values of the PlaybackStatus property
+const (
+	playing = "Playing"
+	paused  = "Paused"
+	stopped = "Stopped"
+)
+
+// Player exposes the AudioPanel on D-Bus as an MPRIS2 media player, so that desktop media
+// keys and status bars can control it. The properties are refreshed on every
+// domain.PositionTick.
+type Player struct {
+	conn   *dbus.Conn
+	panel  *audiopanel.AudioPanel
+	props  *prop.Properties
+	logger *log.Logger
+	sub    domain.Subscription
+	// mu guards last, which is written from the publisher tick and by D-Bus callers. It is
+	// never held while setting the properties, which have a lock of their own.
+	mu   sync.Mutex
+	last snapshot
+}
+
+// snapshot holds the property values last sent, so that PropertiesChanged is only
+// emitted for properties that have actually changed
+type snapshot struct {
+	status  string
+	trackId dbus.ObjectPath
+	length  time.Duration
+	volume  float64
+	rate    float64
+}
+
+// StartSession connects to the session bus and starts the Player on it
+func StartSession(panel *audiopanel.AudioPanel, logger *log.Logger) (*Player, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	player, err := Start(conn, panel, logger)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return player, nil
+}
+
+// methodNames maps methods whose names differ in Go to their D-Bus names
+var methodNames = map[string]string{"SeekBy": "Seek"}
+
+// playerMethods returns the introspection data for the player methods under their
+// D-Bus names
+func playerMethods(m methods) []introspect.Method {
+	introspected := introspect.Methods(m)
+	for i := range introspected {
+		if name, ok := methodNames[introspected[i].Name]; ok {
+			introspected[i].Name = name
+		}
+	}
+	return introspected
+}
+
+// Start claims the MPRIS bus name on conn and exports the media player interfaces
+func Start(conn *dbus.Conn, panel *audiopanel.AudioPanel, logger *log.Logger) (*Player, error) {
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("%s is already taken", busName)
+	}
+
+	p := &Player{conn: conn, panel: panel, logger: logger}
+	p.last = snapshot{status: stopped, trackId: noTrack, volume: 1, rate: 1}
+
+	if err = conn.Export(root{}, objectPath, rootIface); err != nil {
+		return nil, err
+	}
+	if err = conn.ExportWithMap(methods{p}, methodNames, objectPath, playerIface); err != nil {
+		return nil, err
+	}
+	if p.props, err = prop.Export(conn, objectPath, p.properties()); err != nil {
+		return nil, err
+	}
+
+	node := &introspect.Node{
+		Name: string(objectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: rootIface, Methods: introspect.Methods(root{}), Properties: p.props.Introspection(rootIface)},
+			{
+				Name:       playerIface,
+				Methods:    playerMethods(methods{p}),
+				Properties: p.props.Introspection(playerIface),
+				Signals: []introspect.Signal{
+					{Name: "Seeked", Args: []introspect.Arg{{Name: "Position", Type: "x"}}},
+				},
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return nil, err
+	}
+
+	p.sub = domain.Subscribe(domain.PositionTickTopic, p.onTick)
+	logger.Printf("Exported %s on the session bus", busName)
+
+	return p, nil
+}
+
+// Close stops publishing updates and gives up the bus name
+func (p *Player) Close() error {
+	domain.Unsubscribe(p.sub)
+	_, _ = p.conn.ReleaseName(busName)
+	return p.conn.Close()
+}
+
+// properties declares the properties of both MPRIS interfaces with their initial values
+func (p *Player) properties() prop.Map {
+	return prop.Map{
+		rootIface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: false, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: "Last Player On The Left", Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{"http", "https"}, Emit: prop.EmitConst},
+			"SupportedMimeTypes":  {Value: []string{"audio/mpeg"}, Emit: prop.EmitConst},
+		},
+		playerIface: {
+			"PlaybackStatus": {Value: p.last.status, Emit: prop.EmitTrue},
+			"LoopStatus":     {Value: "None", Emit: prop.EmitConst},
+			"Rate":           {Value: p.last.rate, Emit: prop.EmitTrue, Writable: true, Callback: p.setRate},
+			"Shuffle":        {Value: false, Emit: prop.EmitConst},
+			"Metadata":       {Value: p.metadata(nil, 0), Emit: prop.EmitTrue},
+			"Volume":         {Value: p.last.volume, Emit: prop.EmitTrue, Writable: true, Callback: p.setVolume},
+			"Position":       {Value: int64(0), Emit: prop.EmitFalse},
+			"MinimumRate":    {Value: audiopanel.MinSpeed, Emit: prop.EmitConst},
+			"MaximumRate":    {Value: audiopanel.MaxSpeed, Emit: prop.EmitConst},
+			"CanGoNext":      {Value: true, Emit: prop.EmitConst},
+			"CanGoPrevious":  {Value: true, Emit: prop.EmitConst},
+			"CanPlay":        {Value: true, Emit: prop.EmitConst},
+			"CanPause":       {Value: true, Emit: prop.EmitConst},
+			"CanSeek":        {Value: true, Emit: prop.EmitConst},
+			"CanControl":     {Value: true, Emit: prop.EmitConst},
+		},
+	}
+}
+
+// onTick brings the properties up to date with the player, emitting PropertiesChanged
+// for any that have changed since the last tick
+func (p *Player) onTick(event domain.Event) {
+	tick := event.(domain.PositionTick)
+	state := p.panel.GetPlayerState()
+	episode := p.panel.Playing()
+
+	next := snapshot{status: stopped, trackId: trackIdOf(episode), length: tick.Length, volume: state.Volume, rate: state.Speed}
+	if episode != nil {
+		next.status = paused
+		if tick.Playing {
+			next.status = playing
+		}
+	}
+
+	// The properties are set once p.mu is released, as writes to Volume and Rate call
+	// back into the player with the lock of the properties held
+	p.mu.Lock()
+	last := p.last
+	p.last = next
+	p.mu.Unlock()
+
+	p.props.SetMust(playerIface, "Position", micros(tick.Position))
+	if next.status != last.status {
+		p.props.SetMust(playerIface, "PlaybackStatus", next.status)
+	}
+	if next.trackId != last.trackId || next.length != last.length {
+		p.props.SetMust(playerIface, "Metadata", p.metadata(episode, tick.Length))
+	}
+	if next.volume != last.volume {
+		p.props.SetMust(playerIface, "Volume", next.volume)
+	}
+	if next.rate != last.rate {
+		p.props.SetMust(playerIface, "Rate", next.rate)
+	}
+}
+
+// metadata builds the Metadata property for the episode
+func (p *Player) metadata(episode *clients.Item, length time.Duration) map[string]dbus.Variant {
+	metadata := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(trackIdOf(episode)),
+	}
+	if episode == nil {
+		return metadata
+	}
+
+	metadata["mpris:length"] = dbus.MakeVariant(micros(length))
+	metadata["xesam:title"] = dbus.MakeVariant(episode.Title)
+	metadata["xesam:url"] = dbus.MakeVariant(episode.Enclosure.Url)
+	if episode.Author != "" {
+		metadata["xesam:artist"] = dbus.MakeVariant([]string{episode.Author})
+	}
+	return metadata
+}
+
+// setVolume handles writes to the Volume property
+func (p *Player) setVolume(change *prop.Change) *dbus.Error {
+	if err := p.panel.SetVolume(change.Value.(float64)); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	p.mu.Lock()
+	p.last.volume = change.Value.(float64)
+	p.mu.Unlock()
+	return nil
+}
+
+// setRate handles writes to the Rate property
+func (p *Player) setRate(change *prop.Change) *dbus.Error {
+	if err := p.panel.SetSpeed(change.Value.(float64)); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	p.mu.Lock()
+	p.last.rate = change.Value.(float64)
+	p.mu.Unlock()
+	return nil
+}
+
+// seekTo moves playback to position and announces the jump with the Seeked signal
+func (p *Player) seekTo(position time.Duration) *dbus.Error {
+	if position < 0 {
+		position = 0
+	}
+	if err := p.panel.Seek(position); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	position = p.panel.GetPlayerState().Position
+	if err := p.conn.Emit(objectPath, playerIface+".Seeked", micros(position)); err != nil {
+		p.logger.Printf("Could not emit Seeked: %v", err)
+	}
+	return nil
+}
+
+// trackId derives a D-Bus object path that identifies the episode
+func trackIdOf(episode *clients.Item) dbus.ObjectPath {
+	if episode == nil {
+		return noTrack
+	}
+	return dbus.ObjectPath(fmt.Sprintf("/org/lastplayer/track/t%x", sha1.Sum([]byte(episode.ID()))))
+}
+
+// micros converts a duration to the microseconds used for positions by MPRIS
+func micros(d time.Duration) int64 {
+	return d.Microseconds()
+}
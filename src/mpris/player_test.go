@@ -0,0 +1,105 @@
+package mpris
+
+import (
+	"bufio"
+	"github.com/godbus/dbus/v5"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// privateBus starts a dbus-daemon of its own for the test, skipping the test if there
+// is none installed, and returns its address
+func privateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = daemon.Process.Kill()
+		_ = daemon.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading the address of the dbus-daemon: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// connect opens a connection to the bus at address, closed when the test ends
+func connect(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestSetVolumeWhileTicking(t *testing.T) {
+	address := privateBus(t)
+	panel := audiopanel.FetchAudioPanel().AttachOutput(audiopanel.NewNullOutput(1))
+	player, err := Start(connect(t, address), panel, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain.Unsubscribe(player.sub)
+	remote := connect(t, address).Object(busName, objectPath)
+
+	stop := make(chan struct{})
+	ticking := make(chan struct{})
+	go func() {
+		defer close(ticking)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				player.onTick(domain.PositionTick{})
+			}
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 20; i++ {
+			volume := dbus.MakeVariant(float64(i%10) / 10)
+			call := remote.Call("org.freedesktop.DBus.Properties.Set", 0, playerIface, "Volume", volume)
+			if call.Err != nil {
+				done <- call.Err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("setting the volume deadlocked with the ticks")
+	}
+	close(stop)
+	<-ticking
+
+	if volume := panel.GetPlayerState().Volume; volume != 0.9 {
+		t.Errorf("the volume is %v after setting it to 0.9", volume)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"net/http"
+)
+
+// streamedTopics are the domain topics forwarded to clients of the event stream
+var streamedTopics = []domain.Topic{
+	domain.FeedRefreshedTopic,
+	domain.EpisodeStartedTopic,
+	domain.PlaybackPausedTopic,
+	domain.PositionTickTopic,
+	domain.DownloadProgressTopic,
+	domain.FeedMovedTopic,
+	domain.EpisodeFinishedTopic,
+}
+
+// eventBuffer is the number of events held for a slow client before further events
+// are dropped
+const eventBuffer = 64
+
+// events streams the domain events to the client as server-sent events, named after
+// their topic with the event as JSON in the data field
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.notAllowed(w, http.MethodGet)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.fail(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	// Publish is called from the goroutine that raised the event, so events are handed
+	// over on a channel and dropped rather than blocking the publisher
+	events := make(chan domain.Event, eventBuffer)
+	for _, topic := range streamedTopics {
+		sub := domain.Subscribe(topic, func(event domain.Event) {
+			select {
+			case events <- event:
+			default:
+			}
+		})
+		defer domain.Unsubscribe(sub)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.Printf("Could not encode %s: %v", event.Topic(), err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Topic(), data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
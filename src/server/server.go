@@ -0,0 +1,333 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/control"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server exposes the subscriptions, feeds and AudioPanel over an HTTP/JSON API so that
+// the player can be run headless and driven from other programs
+type Server struct {
+	conf   *app.ConfigFile
+	panel  *audiopanel.AudioPanel
+	logger *log.Logger
+	mux    *http.ServeMux
+	// token, when set, must be sent as a bearer token with every request
+	token string
+	// confLock guards conf, which is shared between concurrent requests
+	confLock sync.Mutex
+}
+
+// errorBody is the JSON body sent with every unsuccessful response
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// feedBody is the JSON body describing a feed and its episodes
+type feedBody struct {
+	Alias   string          `json:"alias"`
+	Url     string          `json:"url"`
+	Channel clients.Channel `json:"channel"`
+}
+
+// queueRequest is the JSON body accepted when enqueueing audio by its URL
+type queueRequest struct {
+	Url   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// New initialises the Server and registers its routes
+func New(conf *app.ConfigFile, panel *audiopanel.AudioPanel, logger *log.Logger) *Server {
+	s := &Server{conf: conf, panel: panel, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/api/subscriptions", s.subscriptions)
+	s.mux.HandleFunc("/api/subscriptions/", s.subscription)
+	s.mux.HandleFunc("/api/feeds/", s.feeds)
+	s.mux.HandleFunc("/api/queue", s.queue)
+	s.mux.HandleFunc("/api/player", s.player)
+	s.mux.HandleFunc("/api/events", s.events)
+	return s
+}
+
+// RequireToken refuses any request that does not send token in an Authorization header
+// of the form "Bearer TOKEN"
+func (s *Server) RequireToken(token string) *Server {
+	s.token = token
+	return s
+}
+
+// ServeHTTP implements http.Handler. Web pages open in the browser of the user can send
+// requests to the API too, so requests from other origins are refused, as are POSTs
+// that are not JSON, which a page could send without the browser first asking the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logger.Printf("%s %s", r.Method, r.URL)
+	if !s.authorised(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.fail(w, http.StatusUnauthorized, errors.New("a valid bearer token is required"))
+		return
+	}
+	if err := s.sameOrigin(r); err != nil {
+		s.fail(w, http.StatusForbidden, err)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			s.fail(w, http.StatusUnsupportedMediaType, errors.New("the body must be sent as application/json"))
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// sameOrigin refuses requests sent by a page from another origin. Without a token the
+// API is only served on loopback, so the request must also be addressed to a loopback
+// host, which a page that has pointed its own hostname at 127.0.0.1 cannot do.
+func (s *Server) sameOrigin(r *http.Request) error {
+	if s.token == "" && !IsLoopback(r.Host) {
+		return fmt.Errorf("requests for %s are not served", r.Host)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if parsed, err := url.Parse(origin); err != nil || !strings.EqualFold(parsed.Host, r.Host) {
+		return fmt.Errorf("requests from %s are not served", origin)
+	}
+	return nil
+}
+
+// IsLoopback reports whether the host, with or without a port, only accepts connections
+// from this machine
+func IsLoopback(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorised reports whether the request carries the token, if one is required
+func (s *Server) authorised(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	sent := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(s.token)) == 1
+}
+
+// subscriptions lists the subscriptions, or adds one on POST
+func (s *Server) subscriptions(w http.ResponseWriter, r *http.Request) {
+	s.confLock.Lock()
+	defer s.confLock.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		s.reply(w, http.StatusOK, s.conf.Config.Subs)
+	case http.MethodPost:
+		var sub app.Subscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			s.fail(w, http.StatusBadRequest, err)
+			return
+		}
+		if sub.Alias == "" || sub.Url == "" {
+			s.fail(w, http.StatusBadRequest, errors.New("alias and url are required"))
+			return
+		}
+		if s.conf.Config.GetByAlias(sub.Alias).Alias != "" {
+			s.fail(w, http.StatusConflict, fmt.Errorf("alias %s is already subscribed", sub.Alias))
+			return
+		}
+		if err := s.conf.Include(sub.Alias, sub.Url); err != nil {
+			s.fail(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.reply(w, http.StatusCreated, sub)
+	default:
+		s.notAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// subscription removes the subscription named in the path on DELETE
+func (s *Server) subscription(w http.ResponseWriter, r *http.Request) {
+	alias := strings.TrimPrefix(r.URL.Path, "/api/subscriptions/")
+	if r.Method != http.MethodDelete {
+		s.notAllowed(w, http.MethodDelete)
+		return
+	}
+
+	s.confLock.Lock()
+	defer s.confLock.Unlock()
+
+	if s.conf.Config.GetByAlias(alias).Alias == "" {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("no subscription with alias %s", alias))
+		return
+	}
+	if err := s.conf.Exclude(alias); err != nil {
+		s.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// feeds routes the paths under /api/feeds/{alias}:
+//
+//	GET  /api/feeds/{alias}?limit=N
+//	GET  /api/feeds/{alias}/episodes/{index}
+//	POST /api/feeds/{alias}/episodes/{index}/play
+//	POST /api/feeds/{alias}/episodes/{index}/enqueue
+func (s *Server) feeds(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/feeds/"), "/"), "/")
+
+	feed, err := s.fetch(parts[0])
+	if err != nil {
+		s.fail(w, http.StatusNotFound, err)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			s.notAllowed(w, http.MethodGet)
+			return
+		}
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(feed.Channel.Item) {
+			feed.Channel.Item = feed.Channel.Item[:limit]
+		}
+		s.reply(w, http.StatusOK, feed)
+		return
+	}
+
+	if len(parts) < 3 || len(parts) > 4 || parts[1] != "episodes" {
+		http.NotFound(w, r)
+		return
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil || index < 0 || index >= len(feed.Channel.Item) {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("no episode %s in %s", parts[2], parts[0]))
+		return
+	}
+	episode := &feed.Channel.Item[index]
+
+	if len(parts) == 3 {
+		if r.Method != http.MethodGet {
+			s.notAllowed(w, http.MethodGet)
+			return
+		}
+		s.reply(w, http.StatusOK, episode)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.notAllowed(w, http.MethodPost)
+		return
+	}
+	switch parts[3] {
+	case "play":
+		err = s.panel.Play(episode)
+	case "enqueue":
+		err = s.panel.Enqueue(episode)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	s.respond(w, err)
+}
+
+// fetch looks up the subscription by alias and retrieves its feed
+func (s *Server) fetch(alias string) (*feedBody, error) {
+	s.confLock.Lock()
+	sub := s.conf.Config.GetByAlias(alias)
+	s.confLock.Unlock()
+	if sub.Alias == "" {
+		return nil, fmt.Errorf("no subscription with alias %s", alias)
+	}
+
+	feed, err := clients.GetContent(sub.Url)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", alias, err)
+	}
+	return &feedBody{Alias: alias, Url: sub.Url, Channel: feed.Channel[0]}, nil
+}
+
+// queue lists the queued episodes, or queues the audio at a URL on POST
+func (s *Server) queue(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.reply(w, http.StatusOK, s.panel.Queue())
+	case http.MethodPost:
+		var req queueRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Url == "" {
+			s.fail(w, http.StatusBadRequest, errors.New("a url is required"))
+			return
+		}
+		s.respond(w, s.panel.Enqueue(clients.ItemFromUrl(req.Url, req.Title)))
+	default:
+		s.notAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// player reports the state of the player, or carries out a control.Request on POST
+func (s *Server) player(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.respond(w, nil)
+	case http.MethodPost:
+		var req control.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.fail(w, http.StatusBadRequest, err)
+			return
+		}
+		s.respond(w, control.Execute(s.panel, req))
+	default:
+		s.notAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// respond replies with the control.Response describing the outcome of a player command
+func (s *Server) respond(w http.ResponseWriter, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusUnprocessableEntity
+	}
+	s.reply(w, status, control.Respond(s.panel, err))
+}
+
+// reply writes body as JSON with the status
+func (s *Server) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Printf("Could not write response: %v", err)
+	}
+}
+
+// fail replies with the error and status
+func (s *Server) fail(w http.ResponseWriter, status int, err error) {
+	s.logger.Printf("%d: %v", status, err)
+	s.reply(w, status, errorBody{Error: err.Error()})
+}
+
+// notAllowed replies that the method is not supported, listing those that are
+func (s *Server) notAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	s.reply(w, http.StatusMethodNotAllowed, errorBody{Error: "method not allowed"})
+}
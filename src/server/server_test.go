@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFeed = `<?xml version="1.0"?><rss version="2.0"><channel><title>Test Feed</title>
+<item><title>Episode</title><enclosure url="%s/missing.mp3" type="audio/mpeg" length="1"/></item>
+</channel></rss>`
+
+// newTestServer serves the API over a subscription to a feed with one episode, whose
+// audio cannot be found
+func newTestServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	discard := func(prefix string) *log.Logger { return log.New(io.Discard, prefix, 0) }
+	clients.InitLoggers(discard)
+
+	feeds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, testFeed, "http://"+r.Host)
+	}))
+	t.Cleanup(feeds.Close)
+
+	conf := &app.ConfigFile{
+		Path:   filepath.Join(t.TempDir(), "config.yaml"),
+		Config: app.Config{Subs: []app.Subscription{{Alias: "test", Url: feeds.URL + "/feed.xml"}}},
+	}
+	panel := audiopanel.FetchAudioPanel().
+		AttachLogger(discard("")).
+		AttachOutput(audiopanel.NewNullOutput(0)).
+		SetCachePath(t.TempDir())
+	api := httptest.NewServer(New(conf, panel, discard("")).RequireToken(token))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// send makes the request to the API, sending the body as JSON if there is one, and
+// returns the response with its body read
+func send(t *testing.T, api *httptest.Server, method, path, body string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		if name == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+	resp, err := api.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(content)
+}
+
+func TestRoutes(t *testing.T) {
+	api := newTestServer(t, "")
+
+	// In order, as they change the subscriptions
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		// contains is a part of the body expected in the response
+		contains string
+	}{
+		{"GET", "/api/subscriptions", "", http.StatusOK, `"alias":"test"`},
+		{"POST", "/api/subscriptions", `{"alias": "new", "url": "https://example.com/feed.xml"}`, http.StatusCreated, `"alias":"new"`},
+		{"POST", "/api/subscriptions", `{"alias": "new", "url": "https://example.com/feed.xml"}`, http.StatusConflict, "already subscribed"},
+		{"POST", "/api/subscriptions", `{"alias": "", "url": "https://example.com/feed.xml"}`, http.StatusBadRequest, "required"},
+		{"POST", "/api/subscriptions", `{"alias":`, http.StatusBadRequest, "error"},
+		{"PUT", "/api/subscriptions", `{}`, http.StatusMethodNotAllowed, "not allowed"},
+		{"DELETE", "/api/subscriptions/new", "", http.StatusNoContent, ""},
+		{"DELETE", "/api/subscriptions/new", "", http.StatusNotFound, "no subscription"},
+		{"GET", "/api/subscriptions/test", "", http.StatusMethodNotAllowed, "not allowed"},
+		{"GET", "/api/feeds/test", "", http.StatusOK, "Test Feed"},
+		{"GET", "/api/feeds/test?limit=0", "", http.StatusOK, `"items":[]`},
+		{"GET", "/api/feeds/missing", "", http.StatusNotFound, "no subscription"},
+		{"POST", "/api/feeds/test", `{}`, http.StatusMethodNotAllowed, "not allowed"},
+		{"GET", "/api/feeds/test/episodes/0", "", http.StatusOK, `"title":"Episode"`},
+		{"GET", "/api/feeds/test/episodes/1", "", http.StatusNotFound, "no episode 1"},
+		{"GET", "/api/feeds/test/seasons/0", "", http.StatusNotFound, ""},
+		{"POST", "/api/feeds/test/episodes/0/play", `{}`, http.StatusUnprocessableEntity, "status error: 404"},
+		{"POST", "/api/feeds/test/episodes/0/rewind", `{}`, http.StatusNotFound, ""},
+		{"GET", "/api/queue", "", http.StatusOK, "[]"},
+		{"POST", "/api/queue", `{}`, http.StatusBadRequest, "a url is required"},
+		{"GET", "/api/player", "", http.StatusOK, `"state"`},
+		{"POST", "/api/player", `{"command": "volume", "args": ["0.5"]}`, http.StatusOK, `"volume":0.5`},
+		{"POST", "/api/player", `{"command": "speed", "args": ["NaN"]}`, http.StatusUnprocessableEntity, "not a number"},
+		{"POST", "/api/player", `{"command": "rewind"}`, http.StatusUnprocessableEntity, "unknown command"},
+		{"POST", "/api/player", `not json`, http.StatusBadRequest, "error"},
+		{"DELETE", "/api/player", "", http.StatusMethodNotAllowed, "not allowed"},
+	}
+	for _, test := range tests {
+		resp, body := send(t, api, test.method, test.path, test.body, nil)
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s gave %d, expected %d: %s", test.method, test.path, resp.StatusCode, test.status, body)
+			continue
+		}
+		if !strings.Contains(body, test.contains) {
+			t.Errorf("%s %s gave %s, expected it to contain %s", test.method, test.path, body, test.contains)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	api := newTestServer(t, "secret")
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"not a bearer token", "secret", http.StatusUnauthorized},
+		{"token", "Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if test.authorization != "" {
+				headers["Authorization"] = test.authorization
+			}
+			resp, body := send(t, api, "GET", "/api/player", "", headers)
+			if resp.StatusCode != test.status {
+				t.Fatalf("gave %d, expected %d: %s", resp.StatusCode, test.status, body)
+			}
+			if test.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("the challenge was %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// With a token the API is served beyond localhost, under any name
+	resp, body := send(t, api, "GET", "/api/player", "", map[string]string{"Authorization": "Bearer secret", "Host": "player.example.com"})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("a request for another host with the token gave %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestCrossSiteRequestsAreRefused(t *testing.T) {
+	api := newTestServer(t, "")
+	state := `{"command": "state"}`
+
+	// A page can send a text/plain POST without the browser asking the API first
+	req, err := http.NewRequest("POST", api.URL+"/api/player", strings.NewReader(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := api.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("a text/plain POST gave %d", resp.StatusCode)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"same origin", map[string]string{"Origin": api.URL}, http.StatusOK},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"null origin", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"localhost", map[string]string{"Host": "localhost:8080"}, http.StatusOK},
+		{"rebound host", map[string]string{"Host": "evil.example:8080"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, body := send(t, api, "POST", "/api/player", state, test.headers)
+			if resp.StatusCode != test.status {
+				t.Errorf("gave %d, expected %d: %s", resp.StatusCode, test.status, body)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	api := newTestServer(t, "")
+
+	resp, err := api.Client().Get(api.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("the stream is sent as %s", contentType)
+	}
+
+	// The headers are only sent once the stream has subscribed to the events
+	domain.Publish(domain.PlaybackPaused{Paused: true})
+
+	// Buffered so that the reader is not left blocking once the test is done with it
+	lines := make(chan string, eventBuffer)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("the stream ended")
+			}
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the event")
+		}
+		return ""
+	}
+
+	for line := next(); line != "event: PlaybackPaused"; line = next() {
+	}
+	data := strings.TrimPrefix(next(), "data: ")
+	var event domain.PlaybackPaused
+	if err = json.Unmarshal([]byte(data), &event); err != nil || !event.Paused {
+		t.Errorf("the event was sent as %s", data)
+	}
+
+	refused, body := send(t, api, "POST", "/api/events", `{}`, nil)
+	if refused.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/events gave %d: %s", refused.StatusCode, body)
+	}
+}
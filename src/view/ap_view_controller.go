@@ -3,67 +3,108 @@ package view
 import (
 	"fmt"
 	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
 	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
 	"github.com/wombatlord/last-player-on-the-left/src/clients"
 	"github.com/wombatlord/last-player-on-the-left/src/domain"
 	"log"
+	"strings"
+	"time"
 )
 
 // APViewController manages the updating of the tview.TextView that shows the current
-// playing episode
+// playing episode, and the ProgressBar beneath it
 type APViewController struct {
-	PanelStateAwareReceiverController
+	EventController
 	lastPlayer     *LastPlayer
 	logger         *log.Logger
 	playingEpisode *clients.Item
+	// downloads holds the latest progress of each download, by url, as downloads start
+	// before the episode they belong to
+	downloads map[string]domain.DownloadProgress
 }
 
 // NewAPViewController initialises the APViewController and provides an interface
 // for dependency injection
 func NewAPViewController(lastPlayer *LastPlayer) *APViewController {
-	a := &APViewController{logger: lastPlayer.GetLogger("APViewController"), lastPlayer: lastPlayer}
+	a := &APViewController{
+		logger:     lastPlayer.GetLogger("APViewController"),
+		lastPlayer: lastPlayer,
+		downloads:  map[string]domain.DownloadProgress{},
+	}
+	lastPlayer.Views.Progress.SetSeekFunc(a.seek)
 	return a
 }
 
-// OnUpdate implements the audiopanel.PlayerStateSubscriber interface
-func (a *APViewController) OnUpdate() {
-	state := a.lastPlayer.AudioPanel.GetPlayerState()
-	a.RenderState(state)
+// Handlers implements the EventController interface. The view is rerendered
+// when an episode starts, when playback is paused, on every position tick and as
+// the episode is downloaded
+func (a *APViewController) Handlers() map[domain.Topic]domain.Handler {
+	return map[domain.Topic]domain.Handler{
+		domain.EpisodeStartedTopic: func(event domain.Event) {
+			a.playingEpisode = event.(domain.EpisodeStarted).Episode
+			a.RenderState(a.lastPlayer.AudioPanel.GetPlayerState())
+		},
+		domain.PlaybackPausedTopic: func(_ domain.Event) {
+			a.RenderState(a.lastPlayer.AudioPanel.GetPlayerState())
+		},
+		domain.PositionTickTopic: func(event domain.Event) {
+			tick := event.(domain.PositionTick)
+			a.RenderState(audiopanel.PlayerState{
+				Position: tick.Position,
+				Length:   tick.Length,
+				Playing:  tick.Playing,
+				Speed:    tick.Speed,
+			})
+		},
+		domain.DownloadProgressTopic: func(event domain.Event) {
+			progress := event.(domain.DownloadProgress)
+			a.downloads[progress.Episode] = progress
+			a.renderBuffered()
+		},
+	}
 }
 
-// RenderState updates the TextView that the APViewController controls
+// RenderState updates the TextView that the APViewController controls, and the
+// ProgressBar
 func (a *APViewController) RenderState(state audiopanel.PlayerState) {
-	title := ""
-	description := ""
+	text := ""
 	if a.playingEpisode != nil {
-		title = a.playingEpisode.Title
-		description = a.playingEpisode.Description
+		facts := make([]string, 0, 4)
+		for _, fact := range episodeFacts(a.playingEpisode) {
+			facts = append(facts, fact[1])
+		}
+		text = fmt.Sprintf("[::b]%s[::-]\n%s", tview.Escape(a.playingEpisode.Title), tview.Escape(strings.Join(facts, " · ")))
 	}
-	playStatus := map[bool]string{true: string(''), false: string('')}
-	playerStatus := fmt.Sprintf(
-		"%s\n%s %s/%s\n%s",
-		title,
-		playStatus[state.Playing],
-		state.Position,
-		state.Length,
-		description,
-	)
-	a.lastPlayer.Views.APView.SetText(playerStatus)
+	a.lastPlayer.Views.APView.SetText(text)
+	a.lastPlayer.Views.Progress.SetState(state)
+	a.renderBuffered()
 }
 
-// Receive updates the internal tracking of the currently playing episode, if it has
-// changed then the view is re-rendered
-func (a *APViewController) Receive(state domain.State) {
-	a.logger.Printf("Received state %+v", state)
-	if state.PlayingEpisode == nil {
+// renderBuffered shows how much of the playing episode has been downloaded. Episodes
+// that were not downloaded are played from the cache and so are fully buffered, while
+// nothing is shown for downloads of unknown size.
+func (a *APViewController) renderBuffered() {
+	if a.playingEpisode == nil {
 		return
 	}
-	if state.PlayingEpisode != a.playingEpisode {
-		if a.playingEpisode == nil || *a.playingEpisode != *state.PlayingEpisode {
-			a.playingEpisode = state.PlayingEpisode
-			a.RenderState(a.lastPlayer.AudioPanel.GetPlayerState())
+	buffered := 1.0
+	if progress, ok := a.downloads[a.playingEpisode.ID()]; ok {
+		buffered = 0
+		if progress.Total > 0 {
+			buffered = float64(progress.Complete) / float64(progress.Total)
 		}
 	}
+	a.lastPlayer.Views.Progress.SetBuffered(buffered)
+}
+
+// seek moves playback to the position clicked on the ProgressBar
+func (a *APViewController) seek(position time.Duration) {
+	if err := a.lastPlayer.AudioPanel.Seek(position); err != nil {
+		a.lastPlayer.Warn("Could not seek: %v", err)
+		return
+	}
+	a.RenderState(a.lastPlayer.AudioPanel.GetPlayerState())
 }
 
 // InputHandler is used here to rerender the view with the updated player state on capture
@@ -1,27 +1,45 @@
 package view
 
 import (
+	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/wombatlord/last-player-on-the-left/src/app"
 	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
 	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/control"
 	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"github.com/wombatlord/last-player-on-the-left/src/mpris"
 	"io/fs"
 	"log"
 	"os"
+	"sync"
 )
 
-type BeforeDraw func(_ tcell.Screen) bool
-
 // Views is the declaration of the full set of views that must be supplied
 // to the LastPlayer on Build
 type Views struct {
-	Root        *tview.Flex
-	TopRow      *tview.Flex
-	EpisodeMenu *tview.List
-	FeedMenu    *tview.List
-	APView      *tview.TextView
+	Pages          *tview.Pages
+	Root           *tview.Flex
+	TopRow         *tview.Flex
+	Column         *tview.Flex
+	EpisodePane    *tview.Flex
+	EpisodeMenu    *tview.List
+	EpisodeFilter  *tview.InputField
+	Details        *tview.TextView
+	FeedMenu       *tview.List
+	Player         *tview.Flex
+	APView         *tview.TextView
+	Progress       *ProgressBar
+	StatusBar      *tview.TextView
+	MessageHistory *tview.TextView
+	ErrorModal     *tview.Modal
+	Search         *tview.Flex
+	SearchInput    *tview.InputField
+	SearchResults  *tview.Table
+	BottomBar      *tview.Pages
+	CommandLine    *tview.InputField
+	Help           *tview.TextView
 }
 
 // Controllers is the declaration of the full set of controllers
@@ -29,37 +47,104 @@ type Views struct {
 type Controllers struct {
 	FeedMenu         *FeedsMenuController
 	EpisodeMenu      *EpisodeMenuController
+	Details          *DetailsController
 	RootController   *RootController
 	APViewController *APViewController
+	StatusBar        *StatusBarController
+	Search           *SearchController
+	Command          *CommandController
+	Help             *HelpController
 }
 
 // LastPlayer extends the tview.Application with our custom functionality
 type LastPlayer struct {
 	*tview.Application
-	Controllers   Controllers
-	Views         Views
-	FocusRing     []tview.Primitive
-	State         *domain.State
-	AudioPanel    *audiopanel.AudioPanel
-	Config        app.Config
+	Controllers Controllers
+	Views       Views
+	FocusRing   []tview.Primitive
+	State       *domain.State
+	AudioPanel  *audiopanel.AudioPanel
+	Config      app.Config
+	configFile  *app.ConfigFile
+	// state is what is remembered between runs without being configured
+	state         *app.StateFile
 	LogFile       *os.File
 	logger        *log.Logger
-	previousState domain.State
+	subscriptions []domain.Subscription
+	errorFocus    tview.Primitive
+	// configuredLayout is the layout from the config and layout the one in use, which
+	// differ when the layout is picked automatically
+	configuredLayout string
+	layout           string
+	// pane is the pane that last had focus, the one shown in the compact layout
+	pane tview.Primitive
 }
 
-// subscribePanelAware is used to subscribe PanelStateAwareController instances to
-// periodic player state updates
-func (lp *LastPlayer) subscribePanelAware(subs ...PanelStateAwareController) {
-	for _, aware := range subs {
-		lp.AudioPanel.SubscribeToState(aware)
+const (
+	mainPage     = "main"
+	messagesPage = "messages"
+	searchPage   = "search"
+	helpPage     = "help"
+	errorPage    = "error"
+)
+
+// subscribeControllers is used to subscribe the handlers of EventController instances
+// to the domain bus. Events are published from arbitrary goroutines so each handler
+// is queued to run on the UI thread, by one goroutine per controller so that each
+// controller handles its events in the order they were published
+func (lp *LastPlayer) subscribeControllers(controllers ...EventController) {
+	for _, controller := range controllers {
+		queue := newEventQueue()
+		go queue.run(lp.Application)
+		for topic, handler := range controller.Handlers() {
+			handler := handler
+			sub := domain.Subscribe(topic, func(event domain.Event) {
+				queue.push(func() { handler(event) })
+			})
+			lp.subscriptions = append(lp.subscriptions, sub)
+		}
 	}
 }
 
-// registerReceivers is used to register ReceiverController instances to be notified
-// of state changes
-func (lp *LastPlayer) registerReceivers(receivers ...ReceiverController) {
-	for _, receiver := range receivers {
-		domain.Register(receiver)
+// eventQueue holds the handlers of the events published to a controller until they can
+// be run on the UI thread. Pushing never blocks, as events are also published from the
+// UI thread, which would otherwise wait on itself.
+type eventQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	wake    chan struct{}
+}
+
+// newEventQueue returns an empty eventQueue
+func newEventQueue() *eventQueue {
+	return &eventQueue{wake: make(chan struct{}, 1)}
+}
+
+// push adds the handler to the end of the queue
+func (q *eventQueue) push(handler func()) {
+	q.mu.Lock()
+	q.pending = append(q.pending, handler)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run queues the handlers onto the UI thread one at a time, in the order they were pushed
+func (q *eventQueue) run(app *tview.Application) {
+	for range q.wake {
+		for {
+			q.mu.Lock()
+			if len(q.pending) == 0 {
+				q.mu.Unlock()
+				break
+			}
+			next := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mu.Unlock()
+			app.QueueUpdateDraw(next)
+		}
 	}
 }
 
@@ -76,86 +161,193 @@ func Build() *LastPlayer {
 	config, _ := app.LoadConfig()
 	initialState := (&domain.State{}).Init()
 	application := &LastPlayer{
-		Application:   tview.NewApplication(),
-		Config:        config.Config,
-		State:         initialState,
-		previousState: *initialState,
+		Application: tview.NewApplication(),
+		Config:      config.Config,
+		configFile:  config,
+		State:       initialState,
 	}
 	application.AudioPanel = audiopanel.
 		FetchAudioPanel().
-		AttachLogger(application.GetLogger("AudioPanel"))
-	application.AudioPanel.
-		SetPublishCallback(
-			func(f func()) { application.QueueUpdateDraw(f) },
-		)
+		AttachLogger(application.GetLogger("AudioPanel")).
+		SetCachePath(application.Config.Cache)
 
-	logfile, _ := os.OpenFile(application.Config.Logs, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+	logfile, _ := application.Config.OpenLog()
 	application.LogFile = logfile
 	log.SetOutput(logfile)
 	clients.InitLoggers(application.GetLogger)
+	clients.SetProgressFunc(func(id, url string, complete, total int64) {
+		domain.Publish(domain.DownloadProgress{Episode: id, Url: url, Complete: complete, Total: total})
+	})
+	clients.SetMoveFunc(func(from, to string) {
+		domain.Publish(domain.FeedMoved{From: from, To: to})
+	})
+	httpClient, httpErr := application.Config.HTTP.Client()
+	if httpErr != nil {
+		httpClient, _ = app.HTTP{}.Client()
+	}
+	clients.SetHTTPClient(httpClient)
+	authErr := application.Config.Authorise()
+	state, stateErr := app.LoadState(app.GetStatePath())
+	application.state = state
+	application.logger = application.GetLogger("LastPlayer")
+
+	if output, err := audiopanel.NewOutput(application.Config.Output); err != nil {
+		application.GetLogger("Build").Printf("Falling back to the speaker: %v", err)
+	} else {
+		application.AudioPanel.AttachOutput(output)
+	}
+
+	// The palette is set before the views are created so that they pick it up
+	theme, themeErr := NewPalette(application.Config.Theme)
+	if themeErr != nil {
+		theme = Themes[DefaultTheme]
+	}
+	theme.setStyles()
 
 	application.Views = Views{
-		Root:        MainFlex(),
-		TopRow:      TopRow(),
-		EpisodeMenu: EpisodeMenu(),
-		FeedMenu:    FeedMenu(),
-		APView:      AudioPanelView(),
+		Pages:          Pages(),
+		Root:           MainFlex(),
+		TopRow:         TopRow(),
+		Column:         Column(),
+		EpisodePane:    EpisodePane(),
+		EpisodeMenu:    EpisodeMenu(),
+		EpisodeFilter:  EpisodeFilter(),
+		Details:        Details(),
+		FeedMenu:       FeedMenu(),
+		Player:         PlayerPane(),
+		APView:         AudioPanelView(),
+		Progress:       Progress(),
+		StatusBar:      StatusBar(),
+		MessageHistory: MessageHistory(),
+		ErrorModal:     ErrorModal(),
+		Search:         SearchPane(),
+		SearchInput:    SearchInput(),
+		SearchResults:  SearchResults(),
+		BottomBar:      BottomBar(),
+		CommandLine:    CommandLine(),
+		Help:           HelpView(),
 	}
 
 	application.Controllers = Controllers{
 		FeedMenu:         NewFeedsController(application),
 		EpisodeMenu:      NewEpisodeMenuController(application),
+		Details:          NewDetailsController(application),
 		APViewController: NewAPViewController(application),
 		RootController:   NewRootController(application),
+		StatusBar:        NewStatusBarController(application),
+		Search:           NewSearchController(application),
+		Command:          NewCommandController(application),
+		Help:             NewHelpController(application),
 	}
 
-	application.registerReceivers(
-		application.Controllers.EpisodeMenu,
-		application.Controllers.APViewController,
-	)
+	keys, err := BindKeys(application.Config.Keymap, application.keySpecs())
+	if err != nil {
+		application.ReportError(fmt.Errorf("%v, using the default keys", err))
+		keys, _ = BindKeys(DefaultProfile, nil)
+	}
+	keys.TypingIn(application.Application)
+	application.SetInputCapture(application.capture)
+	application.EnableMouse(true)
+
+	application.applyTheme(theme)
+	if themeErr != nil {
+		application.ReportError(fmt.Errorf("%v, using the default theme", themeErr))
+	}
+	if httpErr != nil {
+		application.ReportError(fmt.Errorf("http: %v, using the default settings", httpErr))
+	}
+	if authErr != nil {
+		application.ReportError(fmt.Errorf("%v, requesting those feeds without", authErr))
+	}
+	if stateErr != nil {
+		application.ReportError(fmt.Errorf("state: %v, the episodes of every podcast are listed in the order of the feed", stateErr))
+	}
 
-	application.subscribePanelAware(
+	application.subscribeControllers(
+		application.Controllers.FeedMenu,
+		application.Controllers.EpisodeMenu,
 		application.Controllers.APViewController,
+		application.Controllers.StatusBar,
 	)
 
 	application.declareFocusRing(
 		application.Views.FeedMenu,
 		application.Views.EpisodeMenu,
+		application.Views.Details,
 	)
 
+	application.configuredLayout = application.Config.Layout
+	if application.configuredLayout == "" {
+		application.configuredLayout = AutoLayout
+	}
+	if err := checkLayout(application.configuredLayout); err != nil {
+		application.ReportError(fmt.Errorf("%v, picking one to fit the terminal", err))
+		application.configuredLayout = AutoLayout
+	}
+
 	application.setupLayout()
-	application.SetRoot(application.Views.Root, true)
-	application.SetBeforeDrawFunc(application.notifyCheck())
+	application.setupMouse()
+	application.followFocus()
+	application.SetBeforeDrawFunc(application.resize)
+	application.SetRoot(application.Views.Pages, true)
 
 	return application
 }
 
-// notifyCheck compares the state before the last draw and if it
-// has changed, notify is called
-func (lp *LastPlayer) notifyCheck() BeforeDraw {
-	return func(_ tcell.Screen) bool {
-		s := *lp.State
-		if lp.previousState != s {
-			domain.Notify(s)
-		}
-		lp.previousState = s
+// capture is the input capture of the application. It resolves counts and key
+// sequences and carries out motions, ahead of the controllers of the focused pane.
+func (lp *LastPlayer) capture(event *tcell.EventKey) *tcell.EventKey {
+	if event = keymap.Capture(event); event == nil || keymap.typing() {
+		return event
+	}
+	if lp.navigate(event) {
+		return nil
+	}
+	return event
+}
 
-		return false
+// keySpecs returns the key specs of each action configured in the keys section
+func (lp *LastPlayer) keySpecs() map[string][]string {
+	specs := map[string][]string{}
+	for action, keys := range lp.Config.Keys {
+		specs[action] = keys
 	}
+	return specs
 }
 
 // Run overrides the tview.Application Run method and includes a deferred close
 // of the logfile
-func (lp *LastPlayer) Run() error {
+func (lp *LastPlayer) Run() (err error) {
 	_ = os.MkdirAll(lp.Config.Cache, fs.ModeDir+fs.FileMode(0774))
+	if err := clients.LoadResolved(lp.Config.Cache); err != nil {
+		lp.logger.Printf("Could not read the resolved urls: %v", err)
+	}
 	lp.AudioPanel.SpawnPublisher()
+
+	if server, err := control.Listen(control.SocketPath(), lp.AudioPanel, lp.GetLogger("Control")); err != nil {
+		lp.Warn("Remote control is unavailable: %v", err)
+	} else {
+		go server.Serve()
+		defer func() { _ = server.Close() }()
+	}
+
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go lp.watchTheme(app.GetPath(), stopWatching)
+
+	if player, err := mpris.StartSession(lp.AudioPanel, lp.GetLogger("MPRIS")); err != nil {
+		lp.logger.Printf("MPRIS is unavailable: %v", err)
+	} else {
+		defer func() { _ = player.Close() }()
+	}
+
 	defer func(LogFile *os.File) {
-		err := LogFile.Close()
-		if err != nil {
-			log.Fatal(err)
+		for _, sub := range lp.subscriptions {
+			domain.Unsubscribe(sub)
+		}
+		if closeErr := LogFile.Close(); err == nil {
+			err = closeErr
 		}
-		_ = os.RemoveAll(lp.Config.Cache)
-		_ = os.MkdirAll(lp.Config.Cache, fs.ModeDir+fs.FileMode(0774))
 	}(lp.LogFile)
 	return lp.Application.Run()
 }
@@ -163,14 +355,71 @@ func (lp *LastPlayer) Run() error {
 // GetLogger can be used to get a log.Logger with the prefix as passed. This
 // can be accessed inside controllers etc.
 func (lp *LastPlayer) GetLogger(prefix string) *log.Logger {
-	return log.New(lp.LogFile, "[ "+prefix+" ]: ", 0)
+	return app.NewLogger(lp.LogFile, prefix)
+}
+
+// ReportError logs the error and shows it to the user in a modal, leaving the app
+// usable once dismissed. It is safe to call from any goroutine.
+func (lp *LastPlayer) ReportError(err error) {
+	lp.Error(err.Error())
+	go lp.QueueUpdateDraw(func() {
+		if name, _ := lp.Views.Pages.GetFrontPage(); name != errorPage {
+			lp.errorFocus = lp.GetFocus()
+		}
+		lp.Views.ErrorModal.SetText(err.Error())
+		lp.Views.Pages.ShowPage(errorPage)
+		lp.SetFocus(lp.Views.ErrorModal)
+	})
+}
+
+// Info shows an informational message in the status bar
+func (lp *LastPlayer) Info(format string, v ...interface{}) {
+	lp.Controllers.StatusBar.Push(InfoLevel, fmt.Sprintf(format, v...))
+}
+
+// Warn shows a warning in the status bar
+func (lp *LastPlayer) Warn(format string, v ...interface{}) {
+	lp.Controllers.StatusBar.Push(WarnLevel, fmt.Sprintf(format, v...))
+}
+
+// Error shows an error in the status bar, use ReportError for failures that the user
+// must acknowledge
+func (lp *LastPlayer) Error(format string, v ...interface{}) {
+	lp.Controllers.StatusBar.Push(ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+// dismissError hides the error modal and returns focus to where it was when the error
+// was reported
+func (lp *LastPlayer) dismissError(_ int, _ string) {
+	lp.Views.Pages.HidePage(errorPage)
+	if lp.errorFocus != nil {
+		lp.SetFocus(lp.errorFocus)
+	}
 }
 
 // setupLayout manages the nesting and sizes of the various views
 func (lp *LastPlayer) setupLayout() {
-	lp.Views.TopRow.AddItem(lp.Views.FeedMenu, -1, 1, true)
-	lp.Views.TopRow.AddItem(lp.Views.EpisodeMenu, -1, 1, true)
+	// The panes are arranged again for the size of the terminal once it is known, see
+	// resize
+	lp.arrange(pickLayout(lp.configuredLayout, standardWidth, standardHeight))
+
+	// The filter is given no height until it is opened
+	lp.Views.EpisodePane.AddItem(lp.Views.EpisodeMenu, 0, 1, true)
+	lp.Views.EpisodePane.AddItem(lp.Views.EpisodeFilter, 0, 0, false)
+
+	lp.Views.Player.AddItem(lp.Views.Progress, 1, 0, false)
+	lp.Views.Player.AddItem(lp.Views.APView, 0, 1, false)
+
+	lp.Views.BottomBar.AddPage(statusPage, lp.Views.StatusBar, true, true)
+	lp.Views.BottomBar.AddPage(commandPage, lp.Views.CommandLine, true, false)
+
+	lp.Views.Search.AddItem(lp.Views.SearchInput, 1, 0, true)
+	lp.Views.Search.AddItem(lp.Views.SearchResults, 0, 1, false)
 
-	lp.Views.Root.AddItem(lp.Views.TopRow, -1, 4, true)
-	lp.Views.Root.AddItem(lp.Views.APView, -1, 1, false)
+	lp.Views.ErrorModal.SetDoneFunc(lp.dismissError)
+	lp.Views.Pages.AddPage(mainPage, lp.Views.Root, true, true)
+	lp.Views.Pages.AddPage(messagesPage, Popup(lp.Views.MessageHistory, 80, 20), true, false)
+	lp.Views.Pages.AddPage(searchPage, overlay{lp.Views.Search}, true, false)
+	lp.Views.Pages.AddPage(helpPage, Popup(lp.Views.Help, 80, 24), true, false)
+	lp.Views.Pages.AddPage(errorPage, lp.Views.ErrorModal, false, false)
 }
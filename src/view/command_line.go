@@ -0,0 +1,104 @@
+package view
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/control"
+	"log"
+	"strings"
+)
+
+// The pages of the bar at the bottom of the screen
+const (
+	statusPage  = "status"
+	commandPage = "command"
+)
+
+// commands are the command line commands that act on the interface rather than the
+// player. Any other command is sent to the player as a control.Request, so that the
+// command line accepts everything the ctl subcommand does
+var commands = map[string]func(lp *LastPlayer, args []string){
+	"q":        func(lp *LastPlayer, _ []string) { lp.Stop() },
+	"quit":     func(lp *LastPlayer, _ []string) { lp.Stop() },
+	"help":     func(lp *LastPlayer, _ []string) { lp.Controllers.Help.Toggle() },
+	"search":   func(lp *LastPlayer, _ []string) { lp.Controllers.Search.Open() },
+	"messages": func(lp *LastPlayer, _ []string) { lp.Controllers.StatusBar.ToggleHistory() },
+	"open":     func(lp *LastPlayer, args []string) { lp.Controllers.Details.openCommand(args) },
+	"sort":     func(lp *LastPlayer, args []string) { lp.Controllers.EpisodeMenu.sortCommand(args) },
+	"group":    func(lp *LastPlayer, _ []string) { lp.Controllers.EpisodeMenu.toggleSeasons() },
+}
+
+// CommandController runs the commands typed into the command line, such as
+// :seek 12:30 or :speed 1.5
+type CommandController struct {
+	Controller
+	lastPlayer *LastPlayer
+	logger     *log.Logger
+	prevFocus  tview.Primitive
+}
+
+// NewCommandController initialises the CommandController
+func NewCommandController(lastPlayer *LastPlayer) *CommandController {
+	c := &CommandController{
+		lastPlayer: lastPlayer,
+		logger:     lastPlayer.GetLogger("CommandController"),
+	}
+	lastPlayer.Views.CommandLine.SetDoneFunc(c.done)
+	return c
+}
+
+// Open replaces the status bar with the command line and focuses it
+func (c *CommandController) Open() {
+	c.OpenWith("")
+}
+
+// OpenWith opens the command line with the start of a command already typed
+func (c *CommandController) OpenWith(text string) {
+	c.prevFocus = c.lastPlayer.GetFocus()
+	c.lastPlayer.Views.CommandLine.SetText(text)
+	c.lastPlayer.Views.BottomBar.SwitchToPage(commandPage)
+	c.lastPlayer.SetFocus(c.lastPlayer.Views.CommandLine)
+}
+
+// close restores the status bar and returns focus to where it was
+func (c *CommandController) close() {
+	c.lastPlayer.Views.BottomBar.SwitchToPage(statusPage)
+	if c.prevFocus != nil {
+		c.lastPlayer.SetFocus(c.prevFocus)
+	}
+}
+
+// done runs the command on Enter, Escape abandons it
+func (c *CommandController) done(key tcell.Key) {
+	text := c.lastPlayer.Views.CommandLine.GetText()
+	c.close()
+	if key == tcell.KeyEnter {
+		c.Run(text)
+	}
+}
+
+// Run carries out a single command, reporting any failure in the status bar
+func (c *CommandController) Run(text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return
+	}
+	c.logger.Printf("Running :%s", text)
+
+	if command, ok := commands[fields[0]]; ok {
+		command(c.lastPlayer, fields[1:])
+		return
+	}
+	req := control.Request{Command: fields[0], Args: fields[1:]}
+	// The title of an enqueued episode may have spaces in it
+	if req.Command == control.Enqueue && len(req.Args) > 2 {
+		req.Args = []string{req.Args[0], strings.Join(req.Args[1:], " ")}
+	}
+	// Skipping and enqueueing may start a download, so the player is driven off the UI
+	// thread
+	go func() {
+		if err := control.Execute(c.lastPlayer.AudioPanel, req); err != nil {
+			c.lastPlayer.Error(":%s: %v", fields[0], err)
+		}
+	}()
+}
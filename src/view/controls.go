@@ -2,9 +2,7 @@ package view
 
 import (
 	"github.com/gdamore/tcell/v2"
-	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
 	"github.com/wombatlord/last-player-on-the-left/src/domain"
-	"unicode"
 )
 
 // Controller is the interface for views that are expressed
@@ -16,48 +14,145 @@ type Controller interface {
 	InputHandler(event *tcell.EventKey) *tcell.EventKey
 }
 
-// ReceiverController is an interface that gives the controller
-// the capability to be notified if the global state changes
-type ReceiverController interface {
+// EventController is an interface that gives the controller the capability
+// to react to events published on the domain bus. The handlers it declares
+// are subscribed on Build and are always called on the UI thread
+type EventController interface {
 	Controller
-	domain.Receiver
+	Handlers() map[domain.Topic]domain.Handler
 }
 
-// PanelStateAwareController is an interface that extends
-// a Controller by requiring an implementation of OnUpdate
-// which is periodically called with the audio panel state
-type PanelStateAwareController interface {
-	Controller
-	audiopanel.PlayerStateSubscriber
-}
+// Control is the abstraction of a keymapping
+type Control func(event *tcell.EventKey) bool
 
-// PanelStateAwareReceiverController is an interface that combines
-// the functionality of both PanelStateAwareController and ReceiverController
-type PanelStateAwareReceiverController interface {
-	Controller
-	domain.Receiver
-	audiopanel.PlayerStateSubscriber
+// The contexts that a Binding applies in. Global bindings are handled by the
+// RootController ahead of the focused pane, the others by the controller of the pane
+// or popup that has focus. PaneContext bindings apply in every pane and popup.
+const (
+	GlobalContext   = "Global"
+	PaneContext     = "Every pane"
+	PodcastsContext = "Podcasts"
+	EpisodesContext = "Episodes"
+	DetailsContext  = "Details"
+	SearchContext   = "Search"
+	MessagesContext = "Messages"
+	HelpContext     = "Help"
+)
+
+// Contexts lists every context, in the order they are shown in the help
+var Contexts = []string{GlobalContext, PaneContext, PodcastsContext, EpisodesContext, DetailsContext, SearchContext, MessagesContext, HelpContext}
+
+// Binding declares an action that can be bound to keys in the keys section of the
+// config, and the Control that is resolved from its keys
+type Binding struct {
+	Action      string
+	Contexts    []string
+	Description string
+	Defaults    []string
+	Control     *Control
 }
 
-// Control is the abstraction of a keymapping
-type Control func(event *tcell.EventKey) bool
+// in reports whether the binding applies in the context
+func (b Binding) in(context string) bool {
+	for _, c := range b.Contexts {
+		if c == context {
+			return true
+		}
+	}
+	return false
+}
 
-var PlayPause Control = func(event *tcell.EventKey) bool {
-	return event.Key() == tcell.KeyPause || unicode.ToLower(event.Rune()) == 'p'
+// overlaps reports whether the bindings can apply at the same time
+func (b Binding) overlaps(other Binding) bool {
+	if b.in(GlobalContext) || other.in(GlobalContext) || b.in(PaneContext) || other.in(PaneContext) {
+		return true
+	}
+	for _, context := range b.Contexts {
+		if other.in(context) {
+			return true
+		}
+	}
+	return false
 }
 
-var CycleFocus Control = func(event *tcell.EventKey) bool {
-	return event.Key() == tcell.KeyTab
+var (
+	PlayPause    Control
+	CycleFocus   Control
+	FocusRight   Control
+	FocusLeft    Control
+	SelectItem   Control
+	ShowMessages Control
+	Filter       Control
+	Search       Control
+	Enqueue      Control
+	MoveDown     Control
+	MoveUp       Control
+	MoveTop      Control
+	MoveBottom   Control
+	HalfPageDown Control
+	HalfPageUp   Control
+	Command      Control
+	Help         Control
+	Back         Control
+	OpenLink     Control
+	Sort         Control
+	GroupSeasons Control
+)
+
+// Bindings lists every action that can be bound, with its default keys
+var Bindings = []Binding{
+	{"play_pause", []string{GlobalContext}, "Pause or resume playback", []string{"p", "P", "pause"}, &PlayPause},
+	{"cycle_focus", []string{GlobalContext}, "Move focus to the next pane", []string{"tab"}, &CycleFocus},
+	{"focus_right", []string{GlobalContext}, "Focus the pane to the right", []string{"right"}, &FocusRight},
+	{"focus_left", []string{GlobalContext}, "Focus the pane to the left", []string{"left"}, &FocusLeft},
+	{"show_messages", []string{GlobalContext, MessagesContext}, "Show or hide the message history", []string{"m", "M"}, &ShowMessages},
+	{"search", []string{GlobalContext}, "Search every podcast", []string{"s", "S"}, &Search},
+	{"command", []string{GlobalContext}, "Open the command line", nil, &Command},
+	{"help", []string{GlobalContext, HelpContext}, "Show or hide this help", []string{"?"}, &Help},
+	{"select", []string{PodcastsContext, EpisodesContext, SearchContext}, "Open the podcast or play the episode", []string{"enter"}, &SelectItem},
+	{"filter", []string{EpisodesContext, SearchContext}, "Filter the episodes, or return to the search box", []string{"/"}, &Filter},
+	{"sort", []string{EpisodesContext}, "Sort the episodes in the next order", []string{"o", "O"}, &Sort},
+	{"group_seasons", []string{EpisodesContext}, "Group the episodes by season, or stop grouping them", []string{"z", "Z"}, &GroupSeasons},
+	{"enqueue", []string{SearchContext}, "Add the episode to the queue", []string{"e", "E"}, &Enqueue},
+	{"open_link", []string{DetailsContext}, "Open a link from the show notes, with a count the link with that number", []string{"o", "O"}, &OpenLink},
+	{"back", []string{EpisodesContext, SearchContext, MessagesContext, HelpContext}, "Clear the filter or close the popup", []string{"esc"}, &Back},
+	{"move_down", []string{PaneContext}, "Move down", nil, &MoveDown},
+	{"move_up", []string{PaneContext}, "Move up", nil, &MoveUp},
+	{"move_top", []string{PaneContext}, "Move to the top, or to the line given by a count", nil, &MoveTop},
+	{"move_bottom", []string{PaneContext}, "Move to the bottom, or to the line given by a count", nil, &MoveBottom},
+	{"half_page_down", []string{PaneContext}, "Move down half a page", nil, &HalfPageDown},
+	{"half_page_up", []string{PaneContext}, "Move up half a page", nil, &HalfPageUp},
 }
 
-var FocusRight = func(event *tcell.EventKey) bool {
-	return event.Key() == tcell.KeyRight
+// Profile is a set of bindings that replace the defaults of the actions it names. With
+// Counts set, digits typed ahead of a key are taken as a count, as in 5j
+type Profile struct {
+	Keys   map[string][]string
+	Counts bool
 }
 
-var FocusLeft = func(event *tcell.EventKey) bool {
-	return event.Key() == tcell.KeyLeft
+// DefaultProfile is the profile used when none is configured
+const DefaultProfile = "default"
+
+// Profiles are the keymap profiles that can be selected with the keymap setting
+var Profiles = map[string]Profile{
+	DefaultProfile: {},
+	"vim": {
+		Keys: map[string][]string{
+			"move_down":      {"j"},
+			"move_up":        {"k"},
+			"move_top":       {"g g"},
+			"move_bottom":    {"G"},
+			"half_page_down": {"ctrl+d"},
+			"half_page_up":   {"ctrl+u"},
+			"command":        {":"},
+		},
+		Counts: true,
+	},
 }
 
-var SelectItem = func(event *tcell.EventKey) bool {
-	return event.Key() == tcell.KeyEnter
+func init() {
+	if _, err := BindKeys(DefaultProfile, nil); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,217 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"log"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DetailsController shows the details and show notes of the episode highlighted in the
+// episode menu, and opens the links found in the notes
+type DetailsController struct {
+	Controller
+	lastPlayer *LastPlayer
+	logger     *log.Logger
+	episode    *clients.Item
+	links      []string
+}
+
+// NewDetailsController initialises the DetailsController
+func NewDetailsController(lastPlayer *LastPlayer) *DetailsController {
+	d := &DetailsController{
+		lastPlayer: lastPlayer,
+		logger:     lastPlayer.GetLogger("DetailsController"),
+	}
+	lastPlayer.Views.Details.SetInputCapture(d.InputHandler)
+	return d
+}
+
+// Show replaces the details with those of the episode
+func (d *DetailsController) Show(episode *clients.Item) {
+	if episode == d.episode {
+		return
+	}
+	d.episode = episode
+	d.render()
+}
+
+// render writes the facts about the episode followed by its show notes, and lists the
+// links from the notes at the end
+func (d *DetailsController) render() {
+	view := d.lastPlayer.Views.Details
+	view.Clear()
+	d.links = nil
+	if d.episode == nil {
+		return
+	}
+
+	_, _ = fmt.Fprintf(view, "[::b]%s[::-]\n", tview.Escape(d.episode.Title))
+	for _, fact := range episodeFacts(d.episode) {
+		_, _ = fmt.Fprintf(view, "%s%s:[-] %s\n", tag(palette.Secondary), fact[0], tview.Escape(fact[1]))
+	}
+
+	notes, links := RenderShowNotes(d.episode.ShowNotes())
+	d.links = links
+	if notes != "" {
+		_, _ = fmt.Fprintf(view, "\n%s\n", notes)
+	}
+	if len(links) > 0 {
+		_, _ = fmt.Fprintln(view, "\n[::b]Links[::-]")
+		for i, link := range links {
+			_, _ = fmt.Fprintf(view, "%s%s[-] %s\n", tag(palette.Secondary), tview.Escape(fmt.Sprintf("[%d]", i+1)), tview.Escape(link))
+		}
+	}
+	view.ScrollToBeginning()
+}
+
+// episodeFacts returns the label and value of each of the facts the feed gives about
+// the episode
+func episodeFacts(episode *clients.Item) [][2]string {
+	var facts [][2]string
+	if published := formatPublished(episode, time.Now()); published != "" {
+		facts = append(facts, [2]string{"Published", published})
+	}
+	if length, ok := episode.Length(); ok {
+		facts = append(facts, [2]string{"Duration", formatTime(length)})
+	}
+	if episode.Enclosure.Length > 0 {
+		facts = append(facts, [2]string{"Size", formatSize(episode.Enclosure.Length)})
+	}
+	var numbers []string
+	if episode.Season != "" {
+		numbers = append(numbers, "Season "+episode.Season)
+	}
+	if episode.Episode != "" {
+		numbers = append(numbers, "Episode "+episode.Episode)
+	}
+	if len(numbers) > 0 {
+		facts = append(facts, [2]string{"Number", strings.Join(numbers, ", ")})
+	}
+	return facts
+}
+
+// formatPublished returns the date the episode was published and how long before now
+// that was, or the date as the feed gives it if it could not be parsed
+func formatPublished(episode *clients.Item, now time.Time) string {
+	if episode.Published.IsZero() {
+		return strings.TrimSpace(episode.PubDate)
+	}
+	return fmt.Sprintf("%s (%s)", episode.Published.Local().Format("Mon 2 Jan 2006"), ago(episode.Published, now))
+}
+
+// ago describes how long before now the time was, in the largest whole unit
+func ago(then, now time.Time) string {
+	elapsed := now.Sub(then)
+	if elapsed < time.Minute {
+		return "just now"
+	}
+	units := []struct {
+		name   string
+		length time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if count := int(elapsed / unit.length); count > 0 {
+			if count == 1 {
+				return fmt.Sprintf("1 %s ago", unit.name)
+			}
+			return fmt.Sprintf("%d %ss ago", count, unit.name)
+		}
+	}
+	return "just now"
+}
+
+// formatSize formats a number of bytes in the largest unit that keeps it above one
+func formatSize(bytes int64) string {
+	size := float64(bytes)
+	for _, unit := range []string{"B", "KB", "MB"} {
+		if size < 1000 {
+			return fmt.Sprintf("%.3g %s", size, unit)
+		}
+		size /= 1000
+	}
+	return fmt.Sprintf("%.3g GB", size)
+}
+
+// openableSchemes are the schemes of the links that OpenLink hands to the desktop. The
+// show notes come from the feed, so links that could run a program or open a local
+// file are refused.
+var openableSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// OpenLink opens the numbered link from the show notes in the desktop's browser
+func (d *DetailsController) OpenLink(number int) error {
+	if number < 1 || number > len(d.links) {
+		return fmt.Errorf("no link %d, the notes have %d", number, len(d.links))
+	}
+	link := d.links[number-1]
+	parsed, err := url.Parse(link)
+	if err != nil || !openableSchemes[strings.ToLower(parsed.Scheme)] {
+		return fmt.Errorf("will not open %s, only http, https and mailto links are opened", link)
+	}
+	d.logger.Printf("Opening %s", link)
+
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	cmd := exec.Command(opener, link)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener once it exits so that it is not left as a zombie
+	go func() { _ = cmd.Wait() }()
+	d.lastPlayer.Info("Opened %s", link)
+	return nil
+}
+
+// openCommand runs :open N from the command line
+func (d *DetailsController) openCommand(args []string) {
+	if len(args) != 1 {
+		d.lastPlayer.Error(":open: expected the number of a link")
+		return
+	}
+	number, err := strconv.Atoi(args[0])
+	if err == nil {
+		err = d.OpenLink(number)
+	}
+	if err != nil {
+		d.lastPlayer.Error(":open: %v", err)
+	}
+}
+
+// InputHandler opens a link. With a count, as in 2o, the link with that number is
+// opened straight away. Otherwise the only link is opened, or the command line is
+// opened to ask for the number if there are more.
+func (d *DetailsController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
+	if !OpenLink(event) {
+		return event
+	}
+
+	number, counted := keymap.Count(event)
+	switch {
+	case len(d.links) == 0:
+		d.lastPlayer.Info("The show notes have no links")
+		return nil
+	case !counted && len(d.links) > 1:
+		d.lastPlayer.Controllers.Command.OpenWith("open ")
+		return nil
+	}
+	if err := d.OpenLink(number); err != nil {
+		d.lastPlayer.Error("%v", err)
+	}
+	return nil
+}
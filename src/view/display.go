@@ -1,6 +1,7 @@
 package view
 
 import (
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
@@ -9,6 +10,10 @@ import (
 // LastPlayer. This is where to configure things
 // like the border/title etc.
 
+func Pages() *tview.Pages {
+	return tview.NewPages()
+}
+
 func MainFlex() *tview.Flex {
 	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow)
 
@@ -20,6 +25,11 @@ func TopRow() *tview.Flex {
 	return topRow
 }
 
+// Column stacks the episodes above their details in the standard layout
+func Column() *tview.Flex {
+	return tview.NewFlex().SetDirection(tview.FlexRow)
+}
+
 func EpisodeMenu() *tview.List {
 	episodeMenuView := tview.NewList()
 
@@ -29,6 +39,30 @@ func EpisodeMenu() *tview.List {
 	return episodeMenuView
 }
 
+// EpisodePane stacks the episode menu above its filter
+func EpisodePane() *tview.Flex {
+	return tview.NewFlex().SetDirection(tview.FlexRow)
+}
+
+func EpisodeFilter() *tview.InputField {
+	filter := tview.NewInputField().
+		SetLabel("/").
+		SetFieldBackgroundColor(tcell.ColorDefault)
+	return filter
+}
+
+func Details() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetScrollable(true)
+
+	view.SetBorder(true).
+		SetTitle("Details").
+		SetTitleAlign(tview.AlignCenter)
+	return view
+}
+
 func FeedMenu() *tview.List {
 	feedMenu := tview.NewList()
 
@@ -38,11 +72,111 @@ func FeedMenu() *tview.List {
 	return feedMenu
 }
 
+// PlayerPane stacks the progress bar above the playing episode
+func PlayerPane() *tview.Flex {
+	pane := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	pane.SetBorder(true).
+		SetTitle("Player").
+		SetTitleAlign(tview.AlignCenter)
+	return pane
+}
+
 func AudioPanelView() *tview.TextView {
-	view := tview.NewTextView()
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	return view
+}
+
+func Progress() *ProgressBar {
+	return NewProgressBar()
+}
+
+func ErrorModal() *tview.Modal {
+	modal := tview.NewModal().
+		AddButtons([]string{"Dismiss"})
+
+	modal.SetTitle("Error").
+		SetTitleAlign(tview.AlignCenter)
+	return modal
+}
+
+func StatusBar() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	return view
+}
+
+func MessageHistory() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
 
 	view.SetBorder(true).
-		SetTitle("Player").
+		SetTitle("Messages").
 		SetTitleAlign(tview.AlignCenter)
 	return view
 }
+
+// SearchPane stacks the search input above its results
+func SearchPane() *tview.Flex {
+	pane := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	pane.SetBorder(true).
+		SetTitle("Search").
+		SetTitleAlign(tview.AlignCenter)
+	return pane
+}
+
+func SearchInput() *tview.InputField {
+	input := tview.NewInputField().
+		SetLabel("Search: ").
+		SetFieldBackgroundColor(tcell.ColorDefault)
+	return input
+}
+
+func SearchResults() *tview.Table {
+	table := tview.NewTable().
+		SetSelectable(true, false)
+
+	table.SetBorder(true).
+		SetTitle("Results").
+		SetTitleAlign(tview.AlignCenter)
+	return table
+}
+
+func BottomBar() *tview.Pages {
+	return tview.NewPages()
+}
+
+func CommandLine() *tview.InputField {
+	input := tview.NewInputField().
+		SetLabel(":").
+		SetFieldBackgroundColor(tcell.ColorDefault)
+	return input
+}
+
+func HelpView() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+
+	view.SetBorder(true).
+		SetTitle("Help").
+		SetTitleAlign(tview.AlignCenter)
+	return view
+}
+
+// Popup centres the primitive in a frame of the given width and height, over the
+// panes behind it
+func Popup(p tview.Primitive, width, height int) tview.Primitive {
+	return overlay{tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)}
+}
@@ -1,21 +1,34 @@
 package view
 
 import (
+	"fmt"
 	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
 	"github.com/wombatlord/last-player-on-the-left/src/clients"
 	"github.com/wombatlord/last-player-on-the-left/src/domain"
 	"log"
+	"strings"
 )
 
 // EpisodeMenuController Handles input captured from and updates to be
 // displayed in the episode menu
 type EpisodeMenuController struct {
-	ReceiverController
+	EventController
 	feed           *clients.RSSFeed
 	feedIndex      int
 	playingEpisode *clients.Item
-	lastPlayer     *LastPlayer
-	logger         *log.Logger
+	// rows maps each row of the menu to the index of its episode in the feed, as the
+	// filter leaves only some of the episodes listed. The rows of season headings hold
+	// domain.NoItem
+	rows   []int
+	filter string
+	// order is the order the episodes are listed in, and seasons is set when they are
+	// grouped by season
+	order      string
+	seasons    bool
+	lastPlayer *LastPlayer
+	logger     *log.Logger
 }
 
 // NewEpisodeMenuController Initialises the EpisodeMenuController
@@ -23,49 +36,224 @@ func NewEpisodeMenuController(lastPlayer *LastPlayer) *EpisodeMenuController {
 	e := &EpisodeMenuController{
 		lastPlayer: lastPlayer,
 		feedIndex:  domain.NoItem,
+		order:      FeedOrder,
 		logger:     lastPlayer.GetLogger("EpisodeMenuController"),
 	}
 	lastPlayer.Views.EpisodeMenu.SetInputCapture(e.InputHandler)
+	lastPlayer.Views.EpisodeMenu.SetChangedFunc(e.highlight)
+	lastPlayer.Views.EpisodeFilter.
+		SetChangedFunc(e.applyFilter).
+		SetDoneFunc(e.filterDone)
 	return e
 }
 
-// playEpisode retrieves the appropriate feed item and uses
-// the url in its Enclosure to pass to panel.PlayFromUrl which initiates
-// audio playback. It then queues a function to make the information about the
-// currently playing episode available to the rest of the application
-// via the global state.
+// playEpisode retrieves the appropriate feed item and passes it to
+// panel.Play which initiates audio playback and announces the episode
+// to the rest of the application. The download is started off the UI
+// thread and any failure is reported rather than fatal
 func (e *EpisodeMenuController) playEpisode() {
-	episodeIndex := e.lastPlayer.Views.EpisodeMenu.GetCurrentItem()
-	e.playingEpisode = &e.lastPlayer.State.Feed.Channel[0].Item[episodeIndex]
+	if e.feed == nil || len(e.rows) == 0 {
+		return
+	}
+	episodeIndex := e.rows[e.lastPlayer.Views.EpisodeMenu.GetCurrentItem()]
+	if episodeIndex == domain.NoItem {
+		return
+	}
+	episode := &e.feed.Channel[0].Item[episodeIndex]
 
-	panel := e.lastPlayer.AudioPanel
-	panel.PlayFromUrl(e.playingEpisode.Enclosure.Url, e.lastPlayer.GetLogger("Current Streamer"), e.lastPlayer.Config.Cache)
-	go e.lastPlayer.QueueUpdateDraw(func() {
-		e.lastPlayer.State.PlayingEpisode = e.playingEpisode
-	})
+	go func() {
+		if err := e.lastPlayer.AudioPanel.Play(episode); err != nil {
+			e.lastPlayer.ReportError(err)
+		}
+	}()
 }
 
-// Receive is looking out for changes to the feed index
-func (e *EpisodeMenuController) Receive(state domain.State) {
-	e.logger.Printf("Receive called with state %v", state)
-	e.update(state)
+// Handlers implements the EventController interface, the menu is redrawn
+// whenever a feed is selected and the playing episode is recorded in the
+// global state however playback was started, marking it as played
+func (e *EpisodeMenuController) Handlers() map[domain.Topic]domain.Handler {
+	return map[domain.Topic]domain.Handler{
+		domain.FeedSelectedTopic: func(event domain.Event) {
+			e.update(event.(domain.FeedSelected))
+		},
+		domain.EpisodeStartedTopic: func(event domain.Event) {
+			e.playingEpisode = event.(domain.EpisodeStarted).Episode
+			e.lastPlayer.State.PlayingEpisode = e.playingEpisode
+			if err := e.lastPlayer.state.SetPlayed(e.playingEpisode.ID()); err != nil {
+				e.logger.Printf("Could not remember that %s was played: %v", e.playingEpisode.Title, err)
+			}
+			e.refresh()
+		},
+	}
 }
 
 // update sets the view state so that it us redrawn on the next
 // application draw cycle
-func (e *EpisodeMenuController) update(state domain.State) {
-	if e.feedIndex == domain.NoItem || e.feedIndex != state.FeedIndex {
-		alias := "NoItem"
-		if state.FeedIndex > domain.NoItem {
-			alias = e.lastPlayer.Config.Subs[state.FeedIndex].Alias
+func (e *EpisodeMenuController) update(selected domain.FeedSelected) {
+	sub := e.lastPlayer.Config.Subs[selected.Index]
+	e.logger.Printf("Feed changed to %s, redrawing menu", sub.Alias)
+	e.feed = selected.Feed
+	e.feedIndex = selected.Index
+	state := e.lastPlayer.state.Feed(sub.Alias)
+	e.order = state.Sort
+	if checkOrder(e.order) != nil {
+		e.order = FeedOrder
+	}
+	e.seasons = state.Seasons
+	e.closeFilter()
+	e.render()
+}
+
+// render lists the episodes of the feed that match the filter in the chosen order,
+// in the played or unplayed color of the theme
+func (e *EpisodeMenuController) render() {
+	menu := e.lastPlayer.Views.EpisodeMenu
+	menu.Clear()
+	e.rows = e.rows[:0]
+	if e.feed == nil {
+		return
+	}
+
+	items := e.feed.Channel[0].Item
+	listed := 0
+	season := ""
+	for _, i := range orderEpisodes(items, e.order, e.seasons, e.lastPlayer.state.Played) {
+		if !matchEpisode(e.filter, &items[i]) {
+			continue
+		}
+		if e.seasons && (listed == 0 || strings.TrimSpace(items[i].Season) != season) {
+			season = strings.TrimSpace(items[i].Season)
+			e.rows = append(e.rows, domain.NoItem)
+			menu.AddItem(tag(palette.Title)+"[::b]"+tview.Escape(seasonHeading(season)), "", 0, nil)
 		}
-		e.logger.Printf("Feed changed to %s, redrawing menu", alias)
-		e.feedIndex = state.FeedIndex
-		e.lastPlayer.Views.EpisodeMenu.Clear()
-		for _, item := range e.lastPlayer.State.Feed.Channel[0].Item {
-			e.lastPlayer.Views.EpisodeMenu.AddItem(item.Title, item.Enclosure.Url, ' ', nil)
+		listed++
+		e.rows = append(e.rows, i)
+		color := palette.Unplayed
+		if e.lastPlayer.state.Played(items[i].ID()) {
+			color = palette.Played
+		}
+		menu.AddItem(tag(color)+tview.Escape(items[i].Title), items[i].Enclosure.Url, ' ', nil)
+	}
+
+	if listed == 0 {
+		e.lastPlayer.Controllers.Details.Show(nil)
+	}
+
+	title := "Episodes"
+	if e.filter != "" {
+		title = fmt.Sprintf("Episodes (%d of %d)", listed, len(items))
+	}
+	if e.order != FeedOrder {
+		title += ", " + orderNames[e.order]
+	}
+	if e.seasons {
+		title += ", by season"
+	}
+	menu.SetTitle(title)
+}
+
+// highlight shows the details of the episode on the highlighted row
+func (e *EpisodeMenuController) highlight(row int, _ string, _ string, _ rune) {
+	if e.feed == nil || row < 0 || row >= len(e.rows) {
+		return
+	}
+	if e.rows[row] == domain.NoItem {
+		e.lastPlayer.Controllers.Details.Show(nil)
+		return
+	}
+	e.lastPlayer.Controllers.Details.Show(&e.feed.Channel[0].Item[e.rows[row]])
+}
+
+// refresh renders the menu again, keeping the highlighted episode selected wherever it
+// moves to, or the selection on the same row if it is no longer listed
+func (e *EpisodeMenuController) refresh() {
+	menu := e.lastPlayer.Views.EpisodeMenu
+	current := menu.GetCurrentItem()
+	episodeIndex := domain.NoItem
+	if current < len(e.rows) {
+		episodeIndex = e.rows[current]
+	}
+	e.render()
+	for row, i := range e.rows {
+		if i == episodeIndex && i != domain.NoItem {
+			current = row
 		}
 	}
+	menu.SetCurrentItem(current)
+}
+
+// setOrder lists the episodes in the order, grouped by season if seasons is set, and
+// remembers the choice for the feed
+func (e *EpisodeMenuController) setOrder(order string, seasons bool) {
+	if e.feed == nil {
+		e.lastPlayer.Info("Open a podcast to sort its episodes")
+		return
+	}
+	e.order = order
+	e.seasons = seasons
+	e.refresh()
+
+	grouping := ""
+	if seasons {
+		grouping = ", grouped by season"
+	}
+	e.lastPlayer.Info("Episodes sorted %s%s", orderNames[order], grouping)
+
+	alias := e.lastPlayer.Config.Subs[e.feedIndex].Alias
+	if err := e.lastPlayer.state.SetFeed(alias, app.FeedState{Sort: order, Seasons: seasons}); err != nil {
+		e.lastPlayer.Warn("The order of %s could not be saved: %v", alias, err)
+	}
+}
+
+// toggleSeasons groups the episodes by season, or stops grouping them
+func (e *EpisodeMenuController) toggleSeasons() {
+	e.setOrder(e.order, !e.seasons)
+}
+
+// sortCommand runs :sort ORDER from the command line, without an order it moves on to
+// the next one
+func (e *EpisodeMenuController) sortCommand(args []string) {
+	order := nextOrder(e.order)
+	if len(args) > 0 {
+		order = args[0]
+	}
+	if err := checkOrder(order); err != nil {
+		e.lastPlayer.Error(":sort: %v", err)
+		return
+	}
+	e.setOrder(order, e.seasons)
+}
+
+// openFilter shows the filter below the menu and focuses it
+func (e *EpisodeMenuController) openFilter() {
+	e.lastPlayer.Views.EpisodePane.ResizeItem(e.lastPlayer.Views.EpisodeFilter, 1, 0)
+	e.lastPlayer.SetFocus(e.lastPlayer.Views.EpisodeFilter)
+}
+
+// closeFilter clears and hides the filter, the menu must be rendered afterwards
+func (e *EpisodeMenuController) closeFilter() {
+	e.filter = ""
+	e.lastPlayer.Views.EpisodeFilter.SetText("")
+	e.lastPlayer.Views.EpisodePane.ResizeItem(e.lastPlayer.Views.EpisodeFilter, 0, 0)
+}
+
+// applyFilter narrows the menu as the filter is typed
+func (e *EpisodeMenuController) applyFilter(text string) {
+	if text == e.filter {
+		return
+	}
+	e.filter = text
+	e.render()
+}
+
+// filterDone returns focus to the menu. Enter keeps the menu filtered, Escape clears
+// the filter and lists every episode again
+func (e *EpisodeMenuController) filterDone(key tcell.Key) {
+	if key == tcell.KeyEscape || e.filter == "" {
+		e.closeFilter()
+		e.render()
+	}
+	e.lastPlayer.SetFocus(e.lastPlayer.Views.EpisodeMenu)
 }
 
 // InputHandler implements the user input side of the controller interface
@@ -75,5 +263,27 @@ func (e *EpisodeMenuController) InputHandler(event *tcell.EventKey) *tcell.Event
 		return nil
 	}
 
+	if Filter(event) {
+		e.openFilter()
+		return nil
+	}
+
+	if Sort(event) {
+		e.setOrder(nextOrder(e.order), e.seasons)
+		return nil
+	}
+
+	if GroupSeasons(event) {
+		e.toggleSeasons()
+		return nil
+	}
+
+	// Back clears a filter that has been kept after pressing Enter
+	if Back(event) && e.filter != "" {
+		e.closeFilter()
+		e.render()
+		return nil
+	}
+
 	return event
 }
@@ -0,0 +1,135 @@
+package view
+
+import (
+	"fmt"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The orders that the episodes of a feed can be listed in
+const (
+	FeedOrder     = "feed"
+	NewestOrder   = "newest"
+	OldestOrder   = "oldest"
+	DurationOrder = "duration"
+	TitleOrder    = "title"
+	UnplayedOrder = "unplayed"
+)
+
+// Orders lists every order, in the order that the sort key cycles through them
+var Orders = []string{FeedOrder, NewestOrder, OldestOrder, DurationOrder, TitleOrder, UnplayedOrder}
+
+// orderNames describe each order in the status bar
+var orderNames = map[string]string{
+	FeedOrder:     "in feed order",
+	NewestOrder:   "newest first",
+	OldestOrder:   "oldest first",
+	DurationOrder: "shortest first",
+	TitleOrder:    "by title",
+	UnplayedOrder: "unplayed first",
+}
+
+// checkOrder returns an error if the order is not one of Orders
+func checkOrder(order string) error {
+	if _, ok := orderNames[order]; !ok {
+		return fmt.Errorf("unknown order %q, expected one of %v", order, Orders)
+	}
+	return nil
+}
+
+// nextOrder returns the order after the one given, going back to the first after the last
+func nextOrder(order string) string {
+	for i, o := range Orders {
+		if o == order {
+			return Orders[(i+1)%len(Orders)]
+		}
+	}
+	return Orders[0]
+}
+
+// orderEpisodes returns the indices of the items in the order they are to be listed.
+// Episodes without the date or duration being sorted on are listed after those with
+// one, and episodes that sort the same are kept in feed order. With seasons set the
+// episodes are grouped by season first, those without a season coming last. played
+// reports whether the episode with an id has been played.
+func orderEpisodes(items []clients.Item, order string, seasons bool, played func(id string) bool) []int {
+	indices := make([]int, len(items))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	var less func(a, b *clients.Item) bool
+	switch order {
+	case NewestOrder, OldestOrder:
+		less = func(a, b *clients.Item) bool {
+			if a.Published.IsZero() != b.Published.IsZero() {
+				return !a.Published.IsZero()
+			}
+			if order == NewestOrder {
+				return a.Published.After(b.Published)
+			}
+			return a.Published.Before(b.Published)
+		}
+	case DurationOrder:
+		less = func(a, b *clients.Item) bool {
+			aLength, aOk := a.Length()
+			bLength, bOk := b.Length()
+			if aOk != bOk {
+				return aOk
+			}
+			return aLength < bLength
+		}
+	case TitleOrder:
+		less = func(a, b *clients.Item) bool {
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+	case UnplayedOrder:
+		less = func(a, b *clients.Item) bool {
+			return !played(a.ID()) && played(b.ID())
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(indices, func(i, j int) bool {
+			return less(&items[indices[i]], &items[indices[j]])
+		})
+	}
+	if seasons {
+		sort.SliceStable(indices, func(i, j int) bool {
+			return seasonLess(items[indices[i]].Season, items[indices[j]].Season)
+		})
+	}
+	return indices
+}
+
+// seasonLess reports whether season a comes before season b. Numbered seasons come
+// first in number order, then any named ones, then episodes without a season.
+func seasonLess(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b || a == "" {
+		return false
+	}
+	if b == "" {
+		return true
+	}
+	aNumber, aErr := strconv.Atoi(a)
+	bNumber, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return aNumber < bNumber
+	case aErr == nil || bErr == nil:
+		return aErr == nil
+	}
+	return a < b
+}
+
+// seasonHeading returns the heading shown above the episodes of the season
+func seasonHeading(season string) string {
+	season = strings.TrimSpace(season)
+	if season == "" {
+		return "No season"
+	}
+	return "Season " + season
+}
@@ -1,16 +1,19 @@
 package view
 
 import (
+	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/wombatlord/last-player-on-the-left/src/app"
 	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
 	"log"
+	"strings"
 )
 
 // FeedsMenuController manages the feeds menu, it synchronises the
 // current feed with the feed selection in the ui
 type FeedsMenuController struct {
-	Controller
+	EventController
 	lastPlayer *LastPlayer
 	logger     *log.Logger
 }
@@ -29,36 +32,87 @@ func NewFeedsController(application *LastPlayer) *FeedsMenuController {
 	return f
 }
 
-// selectFeed updates the UI with the new feed as selected by the user
+// selectFeed updates the UI with the new feed as selected by the user. The feed
+// is retrieved off the UI thread and any failure is reported rather than fatal
 func (f *FeedsMenuController) selectFeed() {
-	f.logger.Printf(
-		"Pushing feed index %d to state",
-		f.lastPlayer.Views.FeedMenu.GetCurrentItem(),
-	)
+	if f.lastPlayer.Views.FeedMenu.GetItemCount() == 0 {
+		return
+	}
+	index := f.lastPlayer.Views.FeedMenu.GetCurrentItem()
+	_, url := f.lastPlayer.Views.FeedMenu.GetItemText(index)
+	f.logger.Printf("Pushing feed index %d to state", index)
 
-	go f.lastPlayer.QueueUpdateDraw(func() {
-		f.lastPlayer.State.Feed = f.getFeed()
-		f.lastPlayer.State.FeedIndex = f.lastPlayer.Views.FeedMenu.GetCurrentItem()
-	})
+	go func() {
+		feed, err := f.getFeed(url)
+		if err != nil {
+			f.lastPlayer.ReportError(err)
+			return
+		}
+		domain.Publish(domain.FeedRefreshed{Url: url, Feed: feed})
+		f.lastPlayer.QueueUpdateDraw(func() {
+			f.lastPlayer.State.Feed = feed
+			f.lastPlayer.State.FeedIndex = index
+			domain.Publish(domain.FeedSelected{Index: index, Feed: feed})
+		})
+	}()
 }
 
-// getFeed returns the *clients.RSSFeed associated to the current selection in the menu
-func (f *FeedsMenuController) getFeed() *clients.RSSFeed {
-	index := f.lastPlayer.Views.FeedMenu.GetCurrentItem()
-	_, url := f.lastPlayer.Views.FeedMenu.GetItemText(index)
+// Handlers implements the EventController interface, subscriptions are updated when
+// their feed is found to have moved
+func (f *FeedsMenuController) Handlers() map[domain.Topic]domain.Handler {
+	return map[domain.Topic]domain.Handler{
+		domain.FeedMovedTopic: func(event domain.Event) {
+			f.moveFeed(event.(domain.FeedMoved))
+		},
+	}
+}
+
+// moveFeed saves the new url of a feed that has moved in the config and the menu
+func (f *FeedsMenuController) moveFeed(moved domain.FeedMoved) {
+	menu := f.lastPlayer.Views.FeedMenu
+	for i := 0; i < menu.GetItemCount(); i++ {
+		if alias, url := menu.GetItemText(i); url == moved.From {
+			menu.SetItemText(i, alias, moved.To)
+		}
+	}
+
+	aliases, withheld, err := f.lastPlayer.configFile.MoveFeed(moved.From, moved.To)
+	if err != nil {
+		f.lastPlayer.Warn("%s has moved to %s, but the config could not be saved: %v", moved.From, moved.To, err)
+		return
+	}
+	if len(aliases) == 0 {
+		return
+	}
+	f.lastPlayer.Config.Subs = f.lastPlayer.configFile.Config.Subs
+	f.logger.Printf("Moved %s from %s to %s", strings.Join(aliases, ", "), moved.From, moved.To)
+	if len(withheld) > 0 {
+		f.lastPlayer.Warn("%s has moved to another host, %s, its credentials will not be sent there", strings.Join(withheld, ", "), moved.To)
+		return
+	}
+	f.lastPlayer.Info("%s has moved to %s, the subscription is updated", strings.Join(aliases, ", "), moved.To)
+}
+
+// openFeed selects the highlighted feed and moves focus to its episodes
+func (f *FeedsMenuController) openFeed() {
+	f.selectFeed()
+	f.lastPlayer.SetFocus(f.lastPlayer.Views.EpisodeMenu)
+}
+
+// getFeed returns the *clients.RSSFeed found at url
+func (f *FeedsMenuController) getFeed(url string) (*clients.RSSFeed, error) {
 	feed, err := clients.GetContent(url)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("could not load feed %s: %v", url, err)
 	}
 
-	return feed
+	return feed, nil
 }
 
 // InputHandler invokes selectFeed on capturing a tcell.KeyEnter keypress
 func (f *FeedsMenuController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
 	if SelectItem(event) {
-		f.selectFeed()
-		f.lastPlayer.QueueEvent(tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone))
+		f.openFeed()
 	}
 	return event
 }
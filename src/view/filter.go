@@ -0,0 +1,44 @@
+package view
+
+import (
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"strings"
+	"unicode"
+)
+
+// matchEpisode reports whether the episode matches the filter query. The query matches
+// case-insensitively if it appears in the title or description, or fuzzily if its
+// characters appear in order in the title, so that "lpotl 4" finds "Last Podcast On The
+// Left: Episode 400". Spaces in the query are ignored when matching fuzzily.
+func matchEpisode(query string, episode *clients.Item) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	title := strings.ToLower(episode.Title)
+	if strings.Contains(title, query) || strings.Contains(strings.ToLower(episode.Description), query) {
+		return true
+	}
+	return fuzzyMatch(query, title)
+}
+
+// fuzzyMatch reports whether every non-space rune of query appears in text in the same
+// order, both are expected to be lower case
+func fuzzyMatch(query, text string) bool {
+	remaining := []rune(text)
+	for _, r := range query {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		i := 0
+		for i < len(remaining) && remaining[i] != r {
+			i++
+		}
+		if i == len(remaining) {
+			return false
+		}
+		remaining = remaining[i+1:]
+	}
+	return true
+}
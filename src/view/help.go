@@ -0,0 +1,87 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"log"
+	"strings"
+)
+
+// HelpController shows the key bindings and commands in a popup
+type HelpController struct {
+	Controller
+	lastPlayer *LastPlayer
+	logger     *log.Logger
+	prevFocus  tview.Primitive
+}
+
+// NewHelpController initialises the HelpController
+func NewHelpController(lastPlayer *LastPlayer) *HelpController {
+	h := &HelpController{
+		lastPlayer: lastPlayer,
+		logger:     lastPlayer.GetLogger("HelpController"),
+	}
+	lastPlayer.Views.Help.SetInputCapture(h.InputHandler)
+	return h
+}
+
+// render writes the actions that have keys bound to them under a heading for each
+// context they apply in, followed by the commands. The keys are read from the keymap so
+// that the help follows the profile and any keys configured in its place.
+func (h *HelpController) render() {
+	view := h.lastPlayer.Views.Help
+	view.Clear()
+	_, _ = fmt.Fprintf(view, "Keymap: %s\n", keymap.Profile())
+	for _, context := range Contexts {
+		heading := false
+		for _, binding := range Bindings {
+			sequences := keymap.Sequences(binding.Action)
+			if len(sequences) == 0 || !binding.in(context) {
+				continue
+			}
+			if !heading {
+				_, _ = fmt.Fprintf(view, "\n[::b]%s[::-]\n", context)
+				heading = true
+			}
+			keys := make([]string, len(sequences))
+			for i, sequence := range sequences {
+				keys[i] = sequence.String()
+			}
+			_, _ = fmt.Fprintf(view, "  [yellow]%-16s[-] %s\n", tview.Escape(strings.Join(keys, ", ")), binding.Description)
+		}
+	}
+
+	if len(keymap.Sequences("command")) > 0 {
+		_, _ = fmt.Fprintln(view, "\n[::b]Commands[::-]")
+		_, _ = fmt.Fprintln(view, "  :seek 12:30, :seek +30, :speed 1.5, :volume 0.5, :play, :pause, :toggle")
+		_, _ = fmt.Fprintln(view, tview.Escape("  :skip, :enqueue URL [TITLE], :open N, :sort [ORDER], :group, :search, :messages, :help, :quit"))
+	}
+	view.ScrollToBeginning()
+}
+
+// Toggle shows the help popup, or hides it if it is already showing
+func (h *HelpController) Toggle() {
+	pages := h.lastPlayer.Views.Pages
+	if name, _ := pages.GetFrontPage(); name == helpPage {
+		pages.HidePage(helpPage)
+		if h.prevFocus != nil {
+			h.lastPlayer.SetFocus(h.prevFocus)
+		}
+		return
+	}
+
+	h.render()
+	h.prevFocus = h.lastPlayer.GetFocus()
+	pages.ShowPage(helpPage)
+	h.lastPlayer.SetFocus(h.lastPlayer.Views.Help)
+}
+
+// InputHandler closes the help popup, any other keys are left to scroll it
+func (h *HelpController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
+	if Help(event) || Back(event) {
+		h.Toggle()
+		return nil
+	}
+	return event
+}
@@ -0,0 +1,385 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key is a single key press, with any modifiers, parsed from a key spec
+type Key struct {
+	Key  tcell.Key
+	Rune rune
+	Mod  tcell.ModMask
+}
+
+// Sequence is a series of key presses that together trigger a Control
+type Sequence []Key
+
+// keyNames maps the lower case names accepted in key specs to their keys
+var keyNames = namedKeys()
+
+// namedKeys lists the names of the special keys known to tcell, along with some aliases
+func namedKeys() map[string]tcell.Key {
+	names := map[string]tcell.Key{
+		"escape":   tcell.KeyEsc,
+		"return":   tcell.KeyEnter,
+		"pageup":   tcell.KeyPgUp,
+		"pagedown": tcell.KeyPgDn,
+		"del":      tcell.KeyDelete,
+	}
+	for key, name := range tcell.KeyNames {
+		if !strings.HasPrefix(name, "Ctrl-") {
+			names[strings.ToLower(name)] = key
+		}
+	}
+	return names
+}
+
+// ParseKey parses a single key spec. A spec is either a single character, such as p or
+// /, or the name of a special key such as enter, tab, esc, left, pgdn, space or f1. Either
+// may be prefixed with modifiers joined by +, e.g. ctrl+d, alt+enter or shift+tab.
+func ParseKey(spec string) (Key, error) {
+	parts := strings.Split(spec, "+")
+	name := parts[len(parts)-1]
+	modifiers := parts[:len(parts)-1]
+	// A doubled + at the end is the plus key itself, as in ctrl++, as is + on its own
+	if name == "" && len(parts) > 1 && parts[len(parts)-2] == "" {
+		name = "+"
+		modifiers = parts[:len(parts)-2]
+	}
+
+	var mod tcell.ModMask
+	for _, modifier := range modifiers {
+		switch strings.ToLower(modifier) {
+		case "ctrl":
+			mod |= tcell.ModCtrl
+		case "alt", "meta":
+			mod |= tcell.ModAlt
+		case "shift":
+			mod |= tcell.ModShift
+		default:
+			return Key{}, fmt.Errorf("unknown modifier %q in %q", modifier, spec)
+		}
+	}
+
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		switch {
+		case mod&tcell.ModCtrl != 0 && r >= 'a' && r <= 'z':
+			return Key{Key: tcell.KeyCtrlA + tcell.Key(r-'a'), Mod: mod}, nil
+		case mod&tcell.ModCtrl != 0:
+			return Key{}, fmt.Errorf("ctrl can only be combined with a letter or a named key in %q", spec)
+		case mod&tcell.ModShift != 0:
+			return Key{Key: tcell.KeyRune, Rune: []rune(strings.ToUpper(name))[0], Mod: mod &^ tcell.ModShift}, nil
+		}
+		return Key{Key: tcell.KeyRune, Rune: r, Mod: mod}, nil
+	}
+
+	name = strings.ToLower(name)
+	if name == "space" {
+		return Key{Key: tcell.KeyRune, Rune: ' ', Mod: mod}, nil
+	}
+	if name == "tab" && mod&tcell.ModShift != 0 {
+		return Key{Key: tcell.KeyBacktab, Mod: mod &^ tcell.ModShift}, nil
+	}
+	key, ok := keyNames[name]
+	if !ok {
+		return Key{}, fmt.Errorf("unknown key %q in %q", name, spec)
+	}
+	return Key{Key: key, Mod: mod}, nil
+}
+
+// ParseSequence parses a key spec made up of one or more keys separated by spaces,
+// such as "g g"
+func ParseSequence(spec string) (Sequence, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key spec")
+	}
+	sequence := make(Sequence, len(fields))
+	for i, field := range fields {
+		key, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		sequence[i] = key
+	}
+	return sequence, nil
+}
+
+// Matches reports whether the event is a press of the key
+func (k Key) Matches(event *tcell.EventKey) bool {
+	if event.Key() != k.Key {
+		return false
+	}
+	if k.Key == tcell.KeyRune {
+		return event.Rune() == k.Rune && event.Modifiers()&tcell.ModAlt == k.Mod&tcell.ModAlt
+	}
+
+	// Shift is only significant when the binding asks for it and control codes always
+	// arrive with ctrl, whether or not the terminal reports it
+	significant := tcell.ModAlt | tcell.ModCtrl | k.Mod&tcell.ModShift
+	if k.Key < tcell.Key(' ') {
+		significant &^= tcell.ModCtrl
+	}
+	return event.Modifiers()&significant == k.Mod&significant
+}
+
+// String formats the key as a key spec
+func (k Key) String() string {
+	var prefix string
+	if k.Mod&tcell.ModCtrl != 0 && (k.Key < tcell.KeyCtrlA || k.Key > tcell.KeyCtrlZ) {
+		prefix += "ctrl+"
+	}
+	if k.Mod&tcell.ModAlt != 0 {
+		prefix += "alt+"
+	}
+	if k.Mod&tcell.ModShift != 0 {
+		prefix += "shift+"
+	}
+
+	switch {
+	case k.Key == tcell.KeyRune && k.Rune == ' ':
+		return prefix + "space"
+	case k.Key == tcell.KeyRune:
+		return prefix + string(k.Rune)
+	case k.Key >= tcell.KeyCtrlA && k.Key <= tcell.KeyCtrlZ && k.Key != tcell.KeyTab && k.Key != tcell.KeyEnter && k.Key != tcell.KeyBackspace:
+		return prefix + "ctrl+" + string(rune('a'+k.Key-tcell.KeyCtrlA))
+	}
+	return prefix + strings.ToLower(tcell.KeyNames[k.Key])
+}
+
+// String formats the sequence as a key spec
+func (s Sequence) String() string {
+	keys := make([]string, len(s))
+	for i, key := range s {
+		keys[i] = key.String()
+	}
+	return strings.Join(keys, " ")
+}
+
+// hasPrefix reports whether prefix is the start of, or the whole of, the sequence
+func (s Sequence) hasPrefix(prefix Sequence) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Keymap resolves key presses into the actions they are bound to. Single keys are
+// matched by the Control of the action directly, multi-key sequences are tracked by
+// Capture, which must be set as the input capture of the application.
+type Keymap struct {
+	// profile is the name of the profile the bindings were taken from
+	profile   string
+	sequences map[string][]Sequence
+	// pending holds the keys pressed so far of a sequence that has not been completed
+	pending Sequence
+	// completed is the event that last completed a sequence, along with the actions
+	// bound to that sequence
+	completed *tcell.EventKey
+	actions   map[string]bool
+	// counts enables count prefixes, count holds the digits typed so far and counted is
+	// the count that applies to the event countEvent
+	counts     bool
+	count      int
+	counted    int
+	countEvent *tcell.EventKey
+	// typing reports whether keys are currently being typed as text
+	typing func() bool
+}
+
+// keymap holds the bindings the Controls are currently resolved against
+var keymap *Keymap
+
+// BindKeys parses the key specs configured for each action, falling back to the
+// bindings of the profile and then the defaults of any action that is not configured,
+// and resolves the Controls against the resulting Keymap. An error is returned for
+// unknown profiles or actions, bad specs, or keys that are bound to more than one
+// action in the same context.
+func BindKeys(profileName string, configured map[string][]string) (*Keymap, error) {
+	if profileName == "" {
+		profileName = DefaultProfile
+	}
+	profile, ok := Profiles[profileName]
+	if !ok {
+		return nil, fmt.Errorf("keymap: unknown profile %q", profileName)
+	}
+	k := &Keymap{profile: profileName, sequences: map[string][]Sequence{}, counts: profile.Counts, typing: func() bool { return false }}
+
+	known := map[string]bool{}
+	for _, binding := range Bindings {
+		known[binding.Action] = true
+	}
+	for action := range configured {
+		if !known[action] {
+			return nil, fmt.Errorf("keys: unknown action %q", action)
+		}
+	}
+
+	for _, binding := range Bindings {
+		specs, ok := configured[binding.Action]
+		if !ok {
+			specs, ok = profile.Keys[binding.Action]
+		}
+		if !ok {
+			specs = binding.Defaults
+		}
+		for _, spec := range specs {
+			sequence, err := ParseSequence(spec)
+			if err != nil {
+				return nil, fmt.Errorf("keys: %s: %v", binding.Action, err)
+			}
+			k.sequences[binding.Action] = append(k.sequences[binding.Action], sequence)
+		}
+	}
+
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	if k.counts {
+		for action, sequences := range k.sequences {
+			for _, sequence := range sequences {
+				if r := sequence[0].Rune; sequence[0].Key == tcell.KeyRune && r >= '0' && r <= '9' {
+					return nil, fmt.Errorf("keys: %s of %s starts with a digit, which is taken as a count", sequence, action)
+				}
+			}
+		}
+	}
+
+	for _, binding := range Bindings {
+		*binding.Control = k.control(binding.Action)
+	}
+	keymap = k
+	return k, nil
+}
+
+// validate checks that no two actions that can apply at the same time share a key,
+// and that no sequence starts with a key that is bound on its own, as it could never
+// be completed
+func (k *Keymap) validate() error {
+	for i, a := range Bindings {
+		for _, b := range Bindings[i+1:] {
+			overlap := a.overlaps(b)
+			for _, sa := range k.sequences[a.Action] {
+				for _, sb := range k.sequences[b.Action] {
+					equal := len(sa) == len(sb) && sa.hasPrefix(sb)
+					switch {
+					case equal && overlap:
+						return fmt.Errorf("keys: %s is bound to both %s and %s", sa, a.Action, b.Action)
+					case !equal && (sa.hasPrefix(sb) || sb.hasPrefix(sa)):
+						return fmt.Errorf("keys: %s of %s and %s of %s overlap", sa, a.Action, sb, b.Action)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// control returns the Control for the action, which matches any of its single keys,
+// or the final key of any of its sequences
+func (k *Keymap) control(action string) Control {
+	return func(event *tcell.EventKey) bool {
+		if event == k.completed && k.actions[action] {
+			return true
+		}
+		for _, sequence := range k.sequences[action] {
+			if len(sequence) == 1 && sequence[0].Matches(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Count returns the count typed ahead of the event, and whether one was typed at all
+func (k *Keymap) Count(event *tcell.EventKey) (int, bool) {
+	if event == k.countEvent && k.counted > 0 {
+		return k.counted, true
+	}
+	return 1, false
+}
+
+// Profile returns the name of the profile the bindings were taken from
+func (k *Keymap) Profile() string {
+	return k.profile
+}
+
+// Sequences returns the sequences bound to the action
+func (k *Keymap) Sequences(action string) []Sequence {
+	return k.sequences[action]
+}
+
+// TypingIn tells the Keymap to leave keys alone while an input field has focus, so
+// that text can be typed without triggering sequences
+func (k *Keymap) TypingIn(app *tview.Application) *Keymap {
+	k.typing = func() bool {
+		_, typing := app.GetFocus().(*tview.InputField)
+		return typing
+	}
+	return k
+}
+
+// Capture follows the progress of counts and multi-key sequences. The keys of a
+// sequence are swallowed until it is complete, and the final key is passed on to be
+// matched by the Controls of the actions bound to it. A key that does not continue the
+// sequence abandons it and is treated as the start of a new one. Digits typed ahead of
+// a key are swallowed and can be read back with Count once the key arrives.
+func (k *Keymap) Capture(event *tcell.EventKey) *tcell.EventKey {
+	if k.typing() {
+		k.pending, k.count = nil, 0
+		return event
+	}
+
+	if r := event.Rune(); k.counts && len(k.pending) == 0 && event.Key() == tcell.KeyRune &&
+		(r >= '1' && r <= '9' || r == '0' && k.count > 0) {
+		k.count = k.count*10 + int(r-'0')
+		return nil
+	}
+	k.counted, k.countEvent = k.count, event
+
+	for {
+		var next *Key
+		actions := map[string]bool{}
+		for action, sequences := range k.sequences {
+			for _, sequence := range sequences {
+				if len(sequence) < 2 || !sequence.hasPrefix(k.pending) || len(sequence) <= len(k.pending) {
+					continue
+				}
+				key := sequence[len(k.pending)]
+				if !key.Matches(event) {
+					continue
+				}
+				if len(sequence) == len(k.pending)+1 {
+					actions[action] = true
+				} else {
+					next = &key
+				}
+			}
+		}
+
+		switch {
+		case len(actions) > 0:
+			k.pending, k.count = nil, 0
+			k.completed, k.actions = event, actions
+			return event
+		case next != nil:
+			k.pending = append(k.pending, *next)
+			return nil
+		case len(k.pending) > 0:
+			k.pending = nil
+			continue
+		}
+		k.count = 0
+		return event
+	}
+}
@@ -0,0 +1,278 @@
+package view
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"strings"
+	"testing"
+)
+
+// bindDefaultsAfter restores the default bindings once the test is done, as BindKeys
+// resolves the Controls shared by the whole package
+func bindDefaultsAfter(t *testing.T) {
+	t.Cleanup(func() {
+		if _, err := BindKeys(DefaultProfile, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// press returns the event of the key being pressed
+func press(key tcell.Key, r rune, mod tcell.ModMask) *tcell.EventKey {
+	return tcell.NewEventKey(key, r, mod)
+}
+
+// typed returns the event of the character being typed
+func typed(r rune) *tcell.EventKey {
+	return press(tcell.KeyRune, r, tcell.ModNone)
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Key
+	}{
+		{"p", Key{Key: tcell.KeyRune, Rune: 'p'}},
+		{"P", Key{Key: tcell.KeyRune, Rune: 'P'}},
+		{"/", Key{Key: tcell.KeyRune, Rune: '/'}},
+		{"+", Key{Key: tcell.KeyRune, Rune: '+'}},
+		{"alt++", Key{Key: tcell.KeyRune, Rune: '+', Mod: tcell.ModAlt}},
+		{"ctrl+d", Key{Key: tcell.KeyCtrlD, Mod: tcell.ModCtrl}},
+		{"Ctrl+d", Key{Key: tcell.KeyCtrlD, Mod: tcell.ModCtrl}},
+		{"shift+a", Key{Key: tcell.KeyRune, Rune: 'A'}},
+		{"shift+tab", Key{Key: tcell.KeyBacktab}},
+		{"tab", Key{Key: tcell.KeyTab}},
+		{"alt+enter", Key{Key: tcell.KeyEnter, Mod: tcell.ModAlt}},
+		{"return", Key{Key: tcell.KeyEnter}},
+		{"esc", Key{Key: tcell.KeyEsc}},
+		{"escape", Key{Key: tcell.KeyEsc}},
+		{"space", Key{Key: tcell.KeyRune, Rune: ' '}},
+		{"PgDn", Key{Key: tcell.KeyPgDn}},
+		{"pagedown", Key{Key: tcell.KeyPgDn}},
+		{"f1", Key{Key: tcell.KeyF1}},
+		{"ctrl+shift+left", Key{Key: tcell.KeyLeft, Mod: tcell.ModCtrl | tcell.ModShift}},
+	}
+	for _, test := range tests {
+		got, err := ParseKey(test.spec)
+		if err != nil {
+			t.Errorf("ParseKey(%q): %v", test.spec, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseKey(%q) = %+v, expected %+v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestParseKeyFails(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"ctrl++", "ctrl can only be combined with a letter"},
+		{"ctrl+1", "ctrl can only be combined with a letter"},
+		{"hyper+a", `unknown modifier "hyper"`},
+		{"enterr", `unknown key "enterr"`},
+		{"ctrl+", `unknown key ""`},
+		{"", `unknown key ""`},
+	}
+	for _, test := range tests {
+		if key, err := ParseKey(test.spec); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseKey(%q) = %+v, %v, expected an error of %q", test.spec, key, err, test.err)
+		}
+	}
+}
+
+func TestSequenceString(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"g g", "g g"},
+		{"  g   G ", "g G"},
+		{"ctrl+d", "ctrl+d"},
+		{"shift+tab", "backtab"},
+		{"alt+enter", "alt+enter"},
+		{"space", "space"},
+		{"alt++ esc", "alt++ esc"},
+	}
+	for _, test := range tests {
+		sequence, err := ParseSequence(test.spec)
+		if err != nil {
+			t.Errorf("ParseSequence(%q): %v", test.spec, err)
+			continue
+		}
+		if got := sequence.String(); got != test.want {
+			t.Errorf("ParseSequence(%q) is formatted as %q, expected %q", test.spec, got, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "   ", "g enterr"} {
+		if _, err := ParseSequence(spec); err == nil {
+			t.Errorf("ParseSequence(%q) was accepted", spec)
+		}
+	}
+}
+
+func TestKeyMatches(t *testing.T) {
+	tests := []struct {
+		spec  string
+		event *tcell.EventKey
+		want  bool
+	}{
+		{"p", typed('p'), true},
+		{"p", typed('P'), false},
+		{"p", press(tcell.KeyRune, 'p', tcell.ModAlt), false},
+		{"alt+p", press(tcell.KeyRune, 'p', tcell.ModAlt), true},
+		{"alt+p", typed('p'), false},
+		// Terminals differ in whether they report ctrl with control codes
+		{"ctrl+d", press(tcell.KeyCtrlD, 0, tcell.ModCtrl), true},
+		{"ctrl+d", press(tcell.KeyCtrlD, 0, tcell.ModNone), true},
+		{"shift+tab", press(tcell.KeyBacktab, 0, tcell.ModShift), true},
+		{"tab", press(tcell.KeyBacktab, 0, tcell.ModShift), false},
+		// Shift only matters when it is asked for
+		{"left", press(tcell.KeyLeft, 0, tcell.ModShift), true},
+		{"shift+left", press(tcell.KeyLeft, 0, tcell.ModNone), false},
+		{"left", press(tcell.KeyLeft, 0, tcell.ModCtrl), false},
+	}
+	for _, test := range tests {
+		key, err := ParseKey(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := key.Matches(test.event); got != test.want {
+			t.Errorf("%s matching %s gave %v, expected %v", test.spec, test.event.Name(), got, test.want)
+		}
+	}
+}
+
+func TestBindKeys(t *testing.T) {
+	bindDefaultsAfter(t)
+
+	tests := []struct {
+		name       string
+		profile    string
+		configured map[string][]string
+		// err is a part of the error expected, none is expected if empty
+		err string
+	}{
+		{"defaults", "", nil, ""},
+		{"vim", "vim", nil, ""},
+		{"unknown profile", "emacs", nil, `unknown profile "emacs"`},
+		{"unknown action", "", map[string][]string{"rewind": {"r"}}, `unknown action "rewind"`},
+		{"bad spec", "", map[string][]string{"search": {"ctrl+1"}}, "search: ctrl can only be combined"},
+		{"conflict", "", map[string][]string{"search": {"p"}}, "p is bound to both play_pause and search"},
+		{"conflict with a pane", "", map[string][]string{"move_down": {"enter"}}, "enter is bound to both select and move_down"},
+		{"same key in separate contexts", "", map[string][]string{"enqueue": {"o"}}, ""},
+		{"sequence over a key", "vim", map[string][]string{"move_down": {"g"}}, "g of move_down and g g of move_top overlap"},
+		{"key over a sequence", "", map[string][]string{"search": {"s"}, "move_top": {"s s"}}, "overlap"},
+		{"digit with counts", "vim", map[string][]string{"move_down": {"5"}}, "starts with a digit, which is taken as a count"},
+		{"digit without counts", "", map[string][]string{"move_down": {"5"}}, ""},
+		{"configured over a profile", "vim", map[string][]string{"move_top": {"home"}, "move_down": {"g"}}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := BindKeys(test.profile, test.configured)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("gave %v", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("gave %v, expected an error of %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestBindKeysResolvesControls(t *testing.T) {
+	bindDefaultsAfter(t)
+	k, err := BindKeys("vim", map[string][]string{"search": {"alt+s", "f3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Profile() != "vim" {
+		t.Errorf("the keymap is of the %s profile", k.Profile())
+	}
+	if !Search(press(tcell.KeyF3, 0, tcell.ModNone)) || !Search(press(tcell.KeyRune, 's', tcell.ModAlt)) {
+		t.Error("search is not bound to the keys configured")
+	}
+	if Search(typed('s')) {
+		t.Error("search is still bound to its default")
+	}
+	if !MoveDown(typed('j')) || !HalfPageDown(press(tcell.KeyCtrlD, 0, tcell.ModCtrl)) {
+		t.Error("the keys of the vim profile are not bound")
+	}
+	if !PlayPause(typed('p')) {
+		t.Error("an action the profile leaves alone lost its default")
+	}
+}
+
+func TestCapture(t *testing.T) {
+	bindDefaultsAfter(t)
+	k, err := BindKeys("vim", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each step is a key pressed, whether Capture passes it on, and the action and count
+	// it is then taken as
+	type step struct {
+		event  *tcell.EventKey
+		passed bool
+		action Control
+		count  int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"single key", []step{{typed('j'), true, MoveDown, 1}}},
+		{"sequence", []step{{typed('g'), false, nil, 0}, {typed('g'), true, MoveTop, 1}}},
+		{"abandoned sequence", []step{{typed('g'), false, nil, 0}, {typed('j'), true, MoveDown, 1}}},
+		{"abandoned and restarted", []step{
+			{typed('g'), false, nil, 0},
+			{typed('x'), true, nil, 1},
+			{typed('g'), false, nil, 0},
+			{typed('g'), true, MoveTop, 1},
+		}},
+		{"count", []step{{typed('5'), false, nil, 0}, {typed('j'), true, MoveDown, 5}}},
+		{"count of two digits", []step{{typed('1'), false, nil, 0}, {typed('0'), false, nil, 0}, {typed('j'), true, MoveDown, 10}}},
+		{"zero is not a count", []step{{typed('0'), true, nil, 1}}},
+		{"count of a sequence", []step{{typed('3'), false, nil, 0}, {typed('g'), false, nil, 0}, {typed('g'), true, MoveTop, 3}}},
+		{"count is used once", []step{{typed('4'), false, nil, 0}, {typed('k'), true, MoveUp, 4}, {typed('k'), true, MoveUp, 1}}},
+		{"digit inside a sequence", []step{{typed('g'), false, nil, 0}, {typed('2'), true, nil, 1}, {typed('j'), true, MoveDown, 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, s := range test.steps {
+				passed := k.Capture(s.event)
+				if (passed != nil) != s.passed {
+					t.Fatalf("step %d: %s was passed on: %v, expected %v", i, s.event.Name(), passed != nil, s.passed)
+				}
+				if passed == nil {
+					continue
+				}
+				if s.action != nil && !s.action(passed) {
+					t.Fatalf("step %d: %s was not taken as the action expected", i, s.event.Name())
+				}
+				if count, _ := k.Count(passed); count != s.count {
+					t.Fatalf("step %d: %s was given a count of %d, expected %d", i, s.event.Name(), count, s.count)
+				}
+			}
+		})
+	}
+
+	// Keys typed into an input field are left alone, and drop anything pending
+	k.Capture(typed('3'))
+	k.Capture(typed('g'))
+	k.typing = func() bool { return true }
+	if k.Capture(typed('g')) == nil {
+		t.Error("a key typed into an input field was swallowed")
+	}
+	k.typing = func() bool { return false }
+	event := typed('j')
+	if passed := k.Capture(event); passed == nil || !MoveDown(passed) {
+		t.Fatal("a key after typing was not passed on")
+	}
+	if count, counted := k.Count(event); counted {
+		t.Errorf("a count of %d typed before the input field was kept", count)
+	}
+}
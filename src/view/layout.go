@@ -0,0 +1,128 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// The layouts that the panes can be arranged in. AutoLayout picks one of the others to
+// suit the size of the terminal each time it is resized.
+const (
+	AutoLayout     = "auto"
+	CompactLayout  = "compact"
+	StandardLayout = "standard"
+	WideLayout     = "wide"
+)
+
+// Layouts are the layouts that may be configured, in order of width
+var Layouts = []string{AutoLayout, CompactLayout, StandardLayout, WideLayout}
+
+// The smallest terminal sizes that the standard and wide layouts are picked for, an
+// 80x24 terminal gets the compact layout
+const (
+	standardWidth  = 100
+	standardHeight = 30
+	wideWidth      = 160
+)
+
+// compactPlayerHeight fits the progress bar, the title and the facts of the episode in
+// the player's border
+const compactPlayerHeight = 5
+
+// checkLayout returns an error if the layout is not one of Layouts
+func checkLayout(layout string) error {
+	for _, known := range Layouts {
+		if layout == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown layout %q, expected one of %v", layout, Layouts)
+}
+
+// pickLayout returns the layout to use for a terminal of the given size
+func pickLayout(configured string, width, height int) string {
+	if configured != AutoLayout {
+		return configured
+	}
+	switch {
+	case width < standardWidth || height < standardHeight:
+		return CompactLayout
+	case width >= wideWidth:
+		return WideLayout
+	}
+	return StandardLayout
+}
+
+// resize arranges the panes for the size of the screen before it is drawn, if that
+// needs a different layout than the current one
+func (lp *LastPlayer) resize(screen tcell.Screen) bool {
+	width, height := screen.Size()
+	if layout := pickLayout(lp.configuredLayout, width, height); layout != lp.layout {
+		lp.arrange(layout)
+	}
+	return false
+}
+
+// arrange lays out the panes. The compact layout shows one pane at a time above a
+// player of fixed height, the focus keys switch between the panes. The standard layout
+// puts the podcasts beside the episodes with their details below, and the wide layout
+// puts all three side by side.
+func (lp *LastPlayer) arrange(layout string) {
+	lp.logger.Printf("Using the %s layout", layout)
+	lp.layout = layout
+	v := lp.Views
+	v.TopRow.Clear()
+	v.Column.Clear()
+	v.Root.Clear()
+
+	switch layout {
+	case CompactLayout:
+		if lp.pane == nil {
+			lp.pane = v.FeedMenu
+		}
+		v.TopRow.AddItem(lp.pane, 0, 1, true)
+	case StandardLayout:
+		v.Column.AddItem(v.EpisodePane, 0, 3, true)
+		v.Column.AddItem(v.Details, 0, 2, false)
+		v.TopRow.AddItem(v.FeedMenu, 0, 1, true)
+		v.TopRow.AddItem(v.Column, 0, 2, true)
+	case WideLayout:
+		v.TopRow.AddItem(v.FeedMenu, 0, 1, true)
+		v.TopRow.AddItem(v.EpisodePane, 0, 1, true)
+		v.TopRow.AddItem(v.Details, 0, 1, false)
+	}
+
+	v.Root.AddItem(v.TopRow, 0, 4, true)
+	if layout == CompactLayout {
+		v.Root.AddItem(v.Player, compactPlayerHeight, 0, false)
+	} else {
+		v.Root.AddItem(v.Player, 0, 1, false)
+	}
+	v.Root.AddItem(v.BottomBar, 1, 0, false)
+}
+
+// showPane records the pane as the one with focus and shows it in the compact layout,
+// which has room for one pane at a time
+func (lp *LastPlayer) showPane(pane tview.Primitive) {
+	lp.pane = pane
+	if lp.layout == CompactLayout {
+		lp.Views.TopRow.Clear()
+		lp.Views.TopRow.AddItem(pane, 0, 1, true)
+	}
+}
+
+// followFocus shows each pane in the compact layout when it, or a view in it, is
+// focused
+func (lp *LastPlayer) followFocus() {
+	panes := map[*tview.Box]tview.Primitive{
+		lp.Views.FeedMenu.Box:      lp.Views.FeedMenu,
+		lp.Views.EpisodeMenu.Box:   lp.Views.EpisodePane,
+		lp.Views.EpisodeFilter.Box: lp.Views.EpisodePane,
+		lp.Views.Details.Box:       lp.Views.Details,
+	}
+	for box, pane := range panes {
+		pane := pane
+		box.SetFocusFunc(func() { lp.showPane(pane) })
+	}
+}
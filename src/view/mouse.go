@@ -0,0 +1,97 @@
+package view
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"math"
+)
+
+// volumeStep is the change in volume made by each turn of the scroll wheel over the
+// player
+const volumeStep = 0.05
+
+// overlay is a page drawn over the main page. It takes every mouse event inside its
+// area so that clicks do not reach the panes hidden behind it.
+type overlay struct {
+	tview.Primitive
+}
+
+// MouseHandler implements tview.Primitive, consuming events that the overlaid
+// primitive does not
+func (o overlay) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	handler := o.Primitive.MouseHandler()
+	return func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if handler != nil {
+			consumed, capture = handler(action, event, setFocus)
+		}
+		if !consumed {
+			x, y := event.Position()
+			rectX, rectY, width, height := o.GetRect()
+			consumed = x >= rectX && x < rectX+width && y >= rectY && y < rectY+height
+		}
+		return
+	}
+}
+
+// setupMouse adds the mouse actions that tview does not provide. A click focuses a
+// pane and highlights the item under it and the wheel scrolls lists and text views,
+// a double click then opens the feed or plays the episode as Enter does. The wheel
+// over the player changes the volume and a click on the status bar shows the
+// messages.
+func (lp *LastPlayer) setupMouse() {
+	lp.Views.FeedMenu.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftDoubleClick {
+			lp.Controllers.FeedMenu.openFeed()
+		}
+		return action, event
+	})
+	lp.Views.EpisodeMenu.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftDoubleClick {
+			lp.Controllers.EpisodeMenu.playEpisode()
+		}
+		return action, event
+	})
+	lp.Views.SearchResults.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftDoubleClick {
+			lp.Controllers.Search.playSelected()
+		}
+		return action, event
+	})
+
+	lp.Views.Player.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		switch action {
+		case tview.MouseScrollUp:
+			lp.changeVolume(volumeStep)
+			return action, nil
+		case tview.MouseScrollDown:
+			lp.changeVolume(-volumeStep)
+			return action, nil
+		}
+		// Only the progress bar responds to clicks, the player is not a pane to focus
+		if !lp.Views.Progress.InRect(event.Position()) {
+			return action, nil
+		}
+		return action, event
+	})
+
+	lp.Views.StatusBar.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftClick {
+			lp.Controllers.StatusBar.ToggleHistory()
+		}
+		return action, nil
+	})
+}
+
+// changeVolume raises or lowers the volume by the change. The wheel does not raise it
+// above 100%, though it leaves a higher volume set by other means until lowered.
+func (lp *LastPlayer) changeVolume(change float64) {
+	current := lp.AudioPanel.GetPlayerState().Volume
+	// Keep the volume on a whole step despite the rounding of repeated changes
+	volume := math.Round((current+change)/volumeStep) * volumeStep
+	volume = math.Max(0, math.Min(volume, math.Max(1, current)))
+	if err := lp.AudioPanel.SetVolume(volume); err != nil {
+		lp.Warn("%v", err)
+		return
+	}
+	lp.Info("Volume %.0f%%", volume*100)
+}
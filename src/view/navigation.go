@@ -0,0 +1,77 @@
+package view
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// navigate carries out the motion Controls on the focused pane. Each motion is replayed
+// as the arrow, Home and End keys that every tview primitive already understands, so
+// that lists, tables and text views all move in their own way. It reports whether the
+// event was a motion.
+func (lp *LastPlayer) navigate(event *tcell.EventKey) bool {
+	count, counted := keymap.Count(event)
+
+	switch {
+	case MoveDown(event):
+		lp.press(tcell.KeyDown, count)
+	case MoveUp(event):
+		lp.press(tcell.KeyUp, count)
+	case (MoveTop(event) || MoveBottom(event)) && counted:
+		// With a count both go to that line, as in vim
+		lp.press(tcell.KeyHome, 1)
+		lp.press(tcell.KeyDown, count-1)
+	case MoveTop(event):
+		lp.press(tcell.KeyHome, 1)
+	case MoveBottom(event):
+		lp.press(tcell.KeyEnd, 1)
+	case HalfPageDown(event):
+		lp.press(tcell.KeyDown, count*lp.halfPage())
+	case HalfPageUp(event):
+		lp.press(tcell.KeyUp, count*lp.halfPage())
+	default:
+		return false
+	}
+	return true
+}
+
+// press sends a key to the focused primitive the given number of times. Lists wrap
+// around at either end, so their moves are clamped instead.
+func (lp *LastPlayer) press(key tcell.Key, times int) {
+	focus := lp.GetFocus()
+	if list, ok := focus.(*tview.List); ok && (key == tcell.KeyDown || key == tcell.KeyUp) {
+		if key == tcell.KeyUp {
+			times = -times
+		}
+		item := list.GetCurrentItem() + times
+		if item >= list.GetItemCount() {
+			item = list.GetItemCount() - 1
+		}
+		if item < 0 {
+			item = 0
+		}
+		list.SetCurrentItem(item)
+		return
+	}
+
+	if focus == nil || focus.InputHandler() == nil {
+		return
+	}
+	handler := focus.InputHandler()
+	for i := 0; i < times; i++ {
+		handler(tcell.NewEventKey(key, 0, tcell.ModNone), func(p tview.Primitive) { lp.SetFocus(p) })
+	}
+}
+
+// halfPage returns half the height of the focused primitive, the number of rows that
+// a half page motion moves by
+func (lp *LastPlayer) halfPage() int {
+	_, _, _, height := lp.GetFocus().GetRect()
+	if box, ok := lp.GetFocus().(interface{ GetInnerRect() (int, int, int, int) }); ok {
+		_, _, _, height = box.GetInnerRect()
+	}
+	if height < 2 {
+		return 1
+	}
+	return height / 2
+}
@@ -0,0 +1,144 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/audiopanel"
+	"time"
+)
+
+// The cells the bar is drawn with. They differ in shape as well as color so that the
+// bar can still be read when colors are turned off
+const (
+	playedCell     = '█'
+	bufferedCell   = '▒'
+	unbufferedCell = '░'
+)
+
+// ProgressBar shows the position through the playing episode, how much of it has been
+// buffered and the time remaining at the current speed. Clicking the bar seeks to the
+// position clicked.
+type ProgressBar struct {
+	*tview.Box
+	state audiopanel.PlayerState
+	// buffered is the fraction of the episode that has been downloaded, from 0 to 1
+	buffered float64
+	seek     func(position time.Duration)
+	// barX and barWidth are where the bar was last drawn, for mapping clicks to positions
+	barX, barWidth int
+}
+
+// NewProgressBar returns a ProgressBar with nothing playing
+func NewProgressBar() *ProgressBar {
+	return &ProgressBar{Box: tview.NewBox()}
+}
+
+// SetState sets the player state that the bar shows
+func (p *ProgressBar) SetState(state audiopanel.PlayerState) *ProgressBar {
+	p.state = state
+	return p
+}
+
+// SetBuffered sets the fraction of the episode that has been buffered
+func (p *ProgressBar) SetBuffered(fraction float64) *ProgressBar {
+	p.buffered = fraction
+	return p
+}
+
+// SetSeekFunc sets the function called with the position clicked on the bar
+func (p *ProgressBar) SetSeekFunc(seek func(position time.Duration)) *ProgressBar {
+	p.seek = seek
+	return p
+}
+
+// Draw implements tview.Primitive, drawing the play status and position, the bar and
+// the remaining time on a single line
+func (p *ProgressBar) Draw(screen tcell.Screen) {
+	p.Box.DrawForSubclass(screen, p)
+	x, y, width, height := p.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	playStatus := map[bool]string{true: string(''), false: string('')}
+	left := fmt.Sprintf("%s %s ", playStatus[p.state.Playing], formatTime(p.state.Position))
+	right := " -" + formatTime(p.remaining())
+	if p.state.Speed != 0 && p.state.Speed != 1 {
+		right += fmt.Sprintf(" %gx", p.state.Speed)
+	}
+	tview.Print(screen, tview.Escape(left), x, y, width, tview.AlignLeft, palette.Text)
+	tview.Print(screen, tview.Escape(right), x, y, width, tview.AlignRight, palette.Text)
+
+	p.barX = x + tview.TaggedStringWidth(left)
+	p.barWidth = width - tview.TaggedStringWidth(left) - tview.TaggedStringWidth(right)
+	if p.barWidth <= 0 {
+		return
+	}
+	played := p.cells(p.progress())
+	buffered := p.cells(p.buffered)
+	for i := 0; i < p.barWidth; i++ {
+		cell, color := unbufferedCell, palette.ProgressTrack
+		switch {
+		case i < played:
+			cell, color = playedCell, palette.Progress
+		case i < buffered:
+			cell = bufferedCell
+		}
+		screen.SetContent(p.barX+i, y, cell, nil, tcell.StyleDefault.Background(palette.Background).Foreground(color))
+	}
+}
+
+// MouseHandler implements tview.Primitive, seeking to the position of a click on the bar
+func (p *ProgressBar) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return p.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		if action != tview.MouseLeftClick || !p.InRect(x, y) {
+			return false, nil
+		}
+		if x < p.barX || x >= p.barX+p.barWidth || p.state.Length <= 0 || p.seek == nil {
+			return true, nil
+		}
+		fraction := float64(x-p.barX) / float64(p.barWidth)
+		p.seek(time.Duration(fraction * float64(p.state.Length)))
+		return true, nil
+	})
+}
+
+// progress returns the fraction of the episode that has been played
+func (p *ProgressBar) progress() float64 {
+	if p.state.Length <= 0 {
+		return 0
+	}
+	return float64(p.state.Position) / float64(p.state.Length)
+}
+
+// remaining returns the time left to play at the current speed
+func (p *ProgressBar) remaining() time.Duration {
+	remaining := p.state.Length - p.state.Position
+	if remaining < 0 {
+		remaining = 0
+	}
+	if p.state.Speed > 0 {
+		remaining = time.Duration(float64(remaining) / p.state.Speed)
+	}
+	return remaining
+}
+
+// cells returns the number of cells of the bar that cover the fraction of it
+func (p *ProgressBar) cells(fraction float64) int {
+	cells := int(fraction * float64(p.barWidth))
+	if cells > p.barWidth {
+		return p.barWidth
+	}
+	if cells < 0 {
+		return 0
+	}
+	return cells
+}
+
+// formatTime formats the duration as hh:mm:ss
+func formatTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
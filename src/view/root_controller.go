@@ -27,6 +27,11 @@ func NewRootController(lastPlayer *LastPlayer) *RootController {
 // InputHandler implements the global controls. In some cases the events need to propagate
 // through the hierarchy
 func (r *RootController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
+	// Keys typed into an input field are text, not controls
+	if _, typing := r.lastPlayer.GetFocus().(*tview.InputField); typing {
+		return event
+	}
+
 	if CycleFocus(event) {
 		focusIndex := (r.focusRingIndex() + 1) % len(r.lastPlayer.FocusRing)
 		r.lastPlayer.SetFocus(r.lastPlayer.FocusRing[focusIndex])
@@ -34,12 +39,32 @@ func (r *RootController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
 	}
 
 	if FocusRight(event) {
-		r.lastPlayer.SetFocus(r.lastPlayer.FocusRing[1])
+		r.focusBy(1)
 		return nil
 	}
 
 	if FocusLeft(event) {
-		r.lastPlayer.SetFocus(r.lastPlayer.FocusRing[0])
+		r.focusBy(-1)
+		return nil
+	}
+
+	if ShowMessages(event) {
+		r.lastPlayer.Controllers.StatusBar.ToggleHistory()
+		return nil
+	}
+
+	if Command(event) {
+		r.lastPlayer.Controllers.Command.Open()
+		return nil
+	}
+
+	if Help(event) {
+		r.lastPlayer.Controllers.Help.Toggle()
+		return nil
+	}
+
+	if Search(event) {
+		r.lastPlayer.Controllers.Search.Open()
 		return nil
 	}
 
@@ -56,7 +81,7 @@ func (r *RootController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
 // LastPlayer.FocusRing
 func (r *RootController) focusRingIndex() int {
 	var focusIndex int
-	for i := 0; i < 2; i++ {
+	for i := range r.lastPlayer.FocusRing {
 		if r.lastPlayer.FocusRing[i] == r.lastPlayer.GetFocus() {
 			focusIndex = i
 		}
@@ -64,6 +89,19 @@ func (r *RootController) focusRingIndex() int {
 	return focusIndex
 }
 
+// focusBy moves focus the given number of panes along the focus ring, stopping at
+// either end
+func (r *RootController) focusBy(offset int) {
+	focusIndex := r.focusRingIndex() + offset
+	if focusIndex < 0 {
+		focusIndex = 0
+	}
+	if focusIndex >= len(r.lastPlayer.FocusRing) {
+		focusIndex = len(r.lastPlayer.FocusRing) - 1
+	}
+	r.lastPlayer.SetFocus(r.lastPlayer.FocusRing[focusIndex])
+}
+
 // focusedView returns the currently focussed view
 func (r *RootController) focusedView() tview.Primitive {
 	return r.lastPlayer.FocusRing[r.focusRingIndex()]
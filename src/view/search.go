@@ -0,0 +1,234 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/clients"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// transcriptWorkers is the number of transcripts fetched at once in the background
+	transcriptWorkers = 4
+	// transcriptsPerSearch is the most transcripts fetched for each search that is run
+	transcriptsPerSearch = 20
+)
+
+// SearchController searches every subscribed feed and plays or enqueues episodes from
+// the results. The feeds that have not been loaded yet are fetched in the background
+// when the search is opened. Transcripts are only fetched once a search is run, a few
+// of the newest at a time, and the search is run again as they arrive
+type SearchController struct {
+	Controller
+	lastPlayer *LastPlayer
+	logger     *log.Logger
+	query      string
+	// rows maps each row of the results table to its episode, feed headings map to nil
+	rows      []*clients.Item
+	prevFocus tview.Primitive
+	// loading is set while feeds are being fetched in the background, and fetching while
+	// transcripts are
+	loading  int32
+	fetching int32
+}
+
+// NewSearchController initialises the SearchController
+func NewSearchController(lastPlayer *LastPlayer) *SearchController {
+	s := &SearchController{
+		lastPlayer: lastPlayer,
+		logger:     lastPlayer.GetLogger("SearchController"),
+	}
+	lastPlayer.Views.SearchInput.SetDoneFunc(s.inputDone)
+	lastPlayer.Views.SearchResults.SetInputCapture(s.InputHandler)
+	return s
+}
+
+// Open shows the search page with the input focused
+func (s *SearchController) Open() {
+	s.prevFocus = s.lastPlayer.GetFocus()
+	s.lastPlayer.Views.Pages.ShowPage(searchPage)
+	s.lastPlayer.SetFocus(s.lastPlayer.Views.SearchInput)
+	go s.load()
+}
+
+// Close hides the search page and returns focus to where it was
+func (s *SearchController) Close() {
+	s.lastPlayer.Views.Pages.HidePage(searchPage)
+	if s.prevFocus != nil {
+		s.lastPlayer.SetFocus(s.prevFocus)
+	}
+}
+
+// load fetches the subscribed feeds that have not been loaded already, so that they can
+// be searched. It does nothing if a load is already under way.
+func (s *SearchController) load() {
+	if !atomic.CompareAndSwapInt32(&s.loading, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&s.loading, 0)
+
+	for _, sub := range s.lastPlayer.Config.Subs {
+		if _, err := clients.GetContent(sub.Url); err != nil {
+			s.lastPlayer.Warn("Could not search %s: %v", sub.Alias, err)
+		}
+	}
+	s.rerun()
+}
+
+// fetchTranscripts fetches the next few transcripts that have not been fetched, so that
+// they can be searched. It does nothing if a fetch is already under way.
+func (s *SearchController) fetchTranscripts() {
+	if !atomic.CompareAndSwapInt32(&s.fetching, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&s.fetching, 0)
+
+	missing := clients.UnfetchedTranscripts(transcriptsPerSearch)
+	if len(missing) == 0 {
+		return
+	}
+	transcripts := make(chan clients.Transcript)
+	var wg sync.WaitGroup
+	for i := 0; i < transcriptWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for transcript := range transcripts {
+				if _, err := clients.GetTranscript(transcript); err != nil {
+					s.logger.Printf("Could not fetch transcript %s: %v", transcript.Url, err)
+				}
+			}
+		}()
+	}
+	for _, transcript := range missing {
+		transcripts <- transcript
+	}
+	close(transcripts)
+	wg.Wait()
+	s.rerun()
+}
+
+// rerun repeats the current search on the UI thread, picking up anything fetched since
+func (s *SearchController) rerun() {
+	s.lastPlayer.QueueUpdateDraw(func() {
+		if s.query != "" {
+			s.render(clients.Search(s.query))
+		}
+	})
+}
+
+// inputDone runs the search on Enter and moves focus to the results, Escape closes the
+// search
+func (s *SearchController) inputDone(key tcell.Key) {
+	switch key {
+	case tcell.KeyEscape:
+		s.Close()
+	case tcell.KeyEnter:
+		s.query = s.lastPlayer.Views.SearchInput.GetText()
+		s.render(clients.Search(s.query))
+		s.lastPlayer.SetFocus(s.lastPlayer.Views.SearchResults)
+		go s.fetchTranscripts()
+	}
+}
+
+// render fills the results table with the hits, under a heading for each feed
+func (s *SearchController) render(hits []clients.Hit) {
+	table := s.lastPlayer.Views.SearchResults
+	table.Clear()
+	s.rows = s.rows[:0]
+
+	feedUrl := ""
+	for _, hit := range hits {
+		if hit.FeedUrl != feedUrl {
+			feedUrl = hit.FeedUrl
+			table.SetCell(len(s.rows), 0, tview.NewTableCell(s.feedName(hit)).
+				SetAttributes(tcell.AttrBold).
+				SetSelectable(false))
+			s.rows = append(s.rows, nil)
+		}
+		table.SetCell(len(s.rows), 0, tview.NewTableCell("  "+publishedDate(hit.Item)))
+		table.SetCell(len(s.rows), 1, tview.NewTableCell(hit.Item.Title).SetExpansion(1))
+		table.SetCell(len(s.rows), 2, tview.NewTableCell(hit.Match))
+		s.rows = append(s.rows, hit.Item)
+	}
+
+	table.SetTitle(fmt.Sprintf("Results (%d)", len(hits)))
+	table.ScrollToBeginning()
+	if len(s.rows) > 1 {
+		table.Select(1, 0)
+	}
+}
+
+// feedName returns the alias the hit's feed is subscribed under, or its title if it is
+// not subscribed
+func (s *SearchController) feedName(hit clients.Hit) string {
+	for _, sub := range s.lastPlayer.Config.Subs {
+		if sub.Url == hit.FeedUrl {
+			return sub.Alias
+		}
+	}
+	return hit.FeedTitle
+}
+
+// selected returns the episode on the selected row of the results, or nil
+func (s *SearchController) selected() *clients.Item {
+	row, _ := s.lastPlayer.Views.SearchResults.GetSelection()
+	if row < 0 || row >= len(s.rows) {
+		return nil
+	}
+	return s.rows[row]
+}
+
+// publishedDate returns the date the episode was published, or the date as the feed
+// gives it if it could not be parsed
+func publishedDate(episode *clients.Item) string {
+	if episode.Published.IsZero() {
+		return episode.PubDate
+	}
+	return episode.Published.Local().Format("2006-01-02")
+}
+
+// playSelected closes the search and plays the selected episode, starting the download
+// off the UI thread
+func (s *SearchController) playSelected() {
+	if episode := s.selected(); episode != nil {
+		s.Close()
+		go func() {
+			if err := s.lastPlayer.AudioPanel.Play(episode); err != nil {
+				s.lastPlayer.ReportError(err)
+			}
+		}()
+	}
+}
+
+// InputHandler plays the selected episode on Enter and enqueues it with e. Slash goes
+// back to the search input and Back closes the search
+func (s *SearchController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
+	switch {
+	case SelectItem(event):
+		s.playSelected()
+		return nil
+	case Enqueue(event):
+		if episode := s.selected(); episode != nil {
+			// The episode is played straight away if nothing is playing
+			go func() {
+				if err := s.lastPlayer.AudioPanel.Enqueue(episode); err != nil {
+					s.lastPlayer.ReportError(err)
+					return
+				}
+				s.lastPlayer.Info("Queued %s", episode.Title)
+			}()
+		}
+		return nil
+	case Filter(event):
+		s.lastPlayer.SetFocus(s.lastPlayer.Views.SearchInput)
+		return nil
+	case Back(event):
+		s.Close()
+		return nil
+	}
+	return event
+}
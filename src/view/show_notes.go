@@ -0,0 +1,242 @@
+package view
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/rivo/tview"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// whitespace matches the runs of whitespace that HTML collapses to a single space
+	whitespace = regexp.MustCompile(`\s+`)
+	// htmlTag matches the tags left in notes that could not be parsed, a < that does not
+	// start a tag, as in x < y, is left as text
+	htmlTag = regexp.MustCompile(`</?[A-Za-z!][^<>]*>`)
+)
+
+// notesWriter converts show notes from HTML to text marked up with tview style tags, or
+// to plain text when plain is set. Links are replaced by numbered footnotes and collected
+// in links.
+type notesWriter struct {
+	text  strings.Builder
+	plain bool
+	links []string
+	// lists holds the next number of each ordered list the writer is in, and -1 for
+	// each unordered list
+	lists []int
+	// hrefs holds the targets of the links the writer is in
+	hrefs []string
+	// breaks is the number of line breaks to write before the next text, and space is
+	// set when a space is to be written before it
+	breaks int
+	space  bool
+	// skip is set inside elements whose content is not shown, such as scripts
+	skip int
+}
+
+// RenderShowNotes converts show notes from HTML to text for a tview.TextView with
+// dynamic colors. Paragraphs, line breaks, lists and bold text are kept and links are
+// numbered like footnotes, returning their targets in order. Notes that are not valid
+// HTML are rendered as far as they can be read, and as plain text from there.
+func RenderShowNotes(notes string) (string, []string) {
+	return renderNotes(notes, false)
+}
+
+// ShowNotesText converts show notes from HTML to plain text for printing, laid out as
+// RenderShowNotes lays them out but without any markup
+func ShowNotesText(notes string) (string, []string) {
+	return renderNotes(notes, true)
+}
+
+// renderNotes converts show notes from HTML to text, marked up unless plain is set
+func renderNotes(notes string, plain bool) (string, []string) {
+	w := &notesWriter{plain: plain}
+	decoder := xml.NewDecoder(strings.NewReader(notes))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				// Show the rest of the notes as text
+				w.write(htmlTag.ReplaceAllString(notes[offset:], " "))
+			}
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			w.start(t)
+		case xml.EndElement:
+			w.end(t)
+		case xml.CharData:
+			if w.skip == 0 {
+				w.write(string(t))
+			}
+		}
+	}
+	return strings.TrimSpace(w.text.String()), w.links
+}
+
+// start handles an opening tag
+func (w *notesWriter) start(element xml.StartElement) {
+	switch strings.ToLower(element.Name.Local) {
+	case "p", "div", "blockquote", "table", "pre":
+		w.block(2)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.block(2)
+		w.tag("[::b]")
+	case "br":
+		w.breaks++
+	case "tr":
+		w.block(1)
+	case "ul":
+		w.block(w.listBreaks())
+		w.lists = append(w.lists, -1)
+	case "ol":
+		w.block(w.listBreaks())
+		w.lists = append(w.lists, 1)
+	case "li":
+		w.block(1)
+		w.listItem()
+	case "b", "strong":
+		w.tag("[::b]")
+	case "a":
+		w.hrefs = append(w.hrefs, attr(element, "href"))
+	case "script", "style":
+		w.skip++
+	}
+}
+
+// end handles a closing tag
+func (w *notesWriter) end(element xml.EndElement) {
+	switch strings.ToLower(element.Name.Local) {
+	case "p", "div", "blockquote", "table", "pre":
+		w.block(2)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.tag("[::-]")
+		w.block(2)
+	case "ul", "ol":
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+		w.block(w.listBreaks())
+	case "b", "strong":
+		w.tag("[::-]")
+	case "a":
+		if len(w.hrefs) == 0 {
+			return
+		}
+		href := w.hrefs[len(w.hrefs)-1]
+		w.hrefs = w.hrefs[:len(w.hrefs)-1]
+		if href == "" {
+			return
+		}
+		footnote := fmt.Sprintf("[%d]", w.link(href))
+		if w.plain {
+			w.flush()
+			w.text.WriteString(footnote)
+		} else {
+			w.tag(tag(palette.Secondary) + tview.Escape(footnote) + "[-]")
+		}
+	case "script", "style":
+		if w.skip > 0 {
+			w.skip--
+		}
+	}
+}
+
+// write adds text, collapsing whitespace as HTML does
+func (w *notesWriter) write(text string) {
+	text = whitespace.ReplaceAllString(text, " ")
+	if strings.HasPrefix(text, " ") {
+		w.space = true
+	}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return
+	}
+	w.flush()
+	if !w.plain {
+		trimmed = tview.Escape(trimmed)
+	}
+	w.text.WriteString(trimmed)
+	w.space = strings.HasSuffix(text, " ")
+}
+
+// tag adds markup that is not text after any pending line breaks or space, plain text
+// has no markup but the breaks are still written
+func (w *notesWriter) tag(markup string) {
+	w.flush()
+	if !w.plain {
+		w.text.WriteString(markup)
+	}
+}
+
+// flush writes the line breaks or space that are pending before the next text
+func (w *notesWriter) flush() {
+	switch {
+	case w.text.Len() == 0:
+	case w.breaks > 0:
+		w.text.WriteString(strings.Repeat("\n", w.breaks))
+	case w.space:
+		w.text.WriteString(" ")
+	}
+	w.breaks = 0
+	w.space = false
+}
+
+// block ends the current line, leaving a blank line after it when lines is 2
+func (w *notesWriter) block(lines int) {
+	if lines > w.breaks {
+		w.breaks = lines
+	}
+}
+
+// listBreaks returns the breaks around a list, nested lists are not set apart
+func (w *notesWriter) listBreaks() int {
+	if len(w.lists) > 0 {
+		return 1
+	}
+	return 2
+}
+
+// listItem writes the bullet or number of a list item, indented by the depth of the list
+func (w *notesWriter) listItem() {
+	if len(w.lists) == 0 {
+		return
+	}
+	marker := "•"
+	if next := &w.lists[len(w.lists)-1]; *next > 0 {
+		marker = fmt.Sprintf("%d.", *next)
+		*next++
+	}
+	w.flush()
+	w.text.WriteString(strings.Repeat("  ", len(w.lists)-1) + marker + " ")
+}
+
+// link returns the footnote number of the link, the same link is only numbered once
+func (w *notesWriter) link(href string) int {
+	for i, link := range w.links {
+		if link == href {
+			return i + 1
+		}
+	}
+	w.links = append(w.links, href)
+	return len(w.links)
+}
+
+// attr returns the value of the named attribute of the element
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if strings.EqualFold(a.Name.Local, name) {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
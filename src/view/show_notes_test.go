@@ -0,0 +1,70 @@
+package view
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderShowNotes(t *testing.T) {
+	// footnote is the markup of the footnote numbered n
+	footnote := func(n string) string {
+		return tag(palette.Secondary) + "[" + n + "[][-]"
+	}
+
+	tests := []struct {
+		name  string
+		notes string
+		text  string
+		links []string
+	}{
+		{"plain text", "Just  some\n text", "Just some text", nil},
+		{"paragraphs", "<p>Hello <b>world</b></p><p>Second</p>", "Hello [::b]world[::-]\n\nSecond", nil},
+		{"line breaks", "<p>one<br>two<br/>three</p>", "one\ntwo\nthree", nil},
+		{"heading", "<h2>Title</h2>body", "[::b]Title[::-]\n\nbody", nil},
+		{"entities", "<p>Q&amp;A &#39;live&#39;</p>", "Q&A 'live'", nil},
+		{
+			"nested lists",
+			"<ul><li>one</li><li>two<ol><li>a</li><li>b<ul><li>deep</li></ul></li></ol></li></ul><p>after</p>",
+			"• one\n• two\n  1. a\n  2. b\n    • deep\n\nafter",
+			nil,
+		},
+		{"numbering of separate lists", "<ol><li>a</li><li>b</li></ol><ol><li>c</li></ol>", "1. a\n2. b\n\n1. c", nil},
+		{
+			"links",
+			`<p>See <a href="https://a.example">this</a>, <a href=" https://b.example ">that</a> and <a href="https://a.example">this again</a></p>`,
+			"See this" + footnote("1") + ", that" + footnote("2") + " and this again" + footnote("1"),
+			[]string{"https://a.example", "https://b.example"},
+		},
+		{"link without a target", `<a>anchor</a> <a href="">empty</a>`, "anchor empty", nil},
+		{"scripts and styles", "<script>alert('[red]')</script><style>p { color: red }</style><p>shown</p>", "shown", nil},
+		{"nested skipped elements", "<script><script>x</script>y</script>shown", "shown", nil},
+		{"square brackets", "array[0] and [red]text[-]", "array[0[] and [red[]text[-[]", nil},
+		{"square brackets in markup", "<p><b>[red]</b></p>", "[::b][red[][::-]", nil},
+		{"unclosed tags", "<p>ok</p></div></p><b>x</b>", "ok\n\nx", nil},
+		{"broken tag", `<p>ok</p><a href="x>broken <b>bold</b> tail`, "ok\n\nbroken bold tail", nil},
+		{"less than in text", "<p>ok x < y and z</p>", "ok x < y and z", nil},
+		{"brackets after broken markup", "<p>ok</p><< [red]", "ok\n\n<< [red[]", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, links := RenderShowNotes(test.notes)
+			if text != test.text {
+				t.Errorf("rendered as %q, expected %q", text, test.text)
+			}
+			if strings.Join(links, " ") != strings.Join(test.links, " ") || len(links) != len(test.links) {
+				t.Errorf("gave the links %q, expected %q", links, test.links)
+			}
+		})
+	}
+}
+
+func TestShowNotesText(t *testing.T) {
+	notes := `<p>Hello <b>[world]</b> &amp; <a href="https://a.example">more</a></p><ul><li>one</li></ul>`
+	text, links := ShowNotesText(notes)
+	if expected := "Hello [world] & more[1]\n\n• one"; text != expected {
+		t.Errorf("rendered as %q, expected %q", text, expected)
+	}
+	if len(links) != 1 || links[0] != "https://a.example" {
+		t.Errorf("gave the links %q", links)
+	}
+}
@@ -0,0 +1,150 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/domain"
+	"log"
+	"time"
+)
+
+// Level indicates the severity of a status Message
+type Level int
+
+const (
+	InfoLevel Level = iota
+	WarnLevel
+	ErrorLevel
+)
+
+// String returns the label used when rendering a Message at this Level
+func (l Level) String() string {
+	return map[Level]string{InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}[l]
+}
+
+// color returns the tview color tag used when rendering a Message at this Level
+func (l Level) color() string {
+	return tag(map[Level]tcell.Color{InfoLevel: palette.Text, WarnLevel: palette.Warning, ErrorLevel: palette.Error}[l])
+}
+
+// Message is a single notification shown in the status bar
+type Message struct {
+	Level Level
+	Text  string
+	Time  time.Time
+}
+
+// render formats the message with dynamic color tags for display in a tview.TextView
+func (m Message) render() string {
+	return fmt.Sprintf(
+		"%s%s[-] %s%s[-]",
+		tag(palette.Muted),
+		m.Time.Format("15:04:05"),
+		m.Level.color(),
+		tview.Escape(m.Text),
+	)
+}
+
+const (
+	// messageTTL is how long a message is shown in the status bar before it is cleared
+	messageTTL = 5 * time.Second
+	// historyLength is the number of messages kept for the message history popup
+	historyLength = 100
+)
+
+// StatusBarController shows transient messages in the status bar and keeps a history
+// of them that can be browsed in a popup
+type StatusBarController struct {
+	EventController
+	lastPlayer *LastPlayer
+	logger     *log.Logger
+	history    []Message
+	shown      int
+	prevFocus  tview.Primitive
+}
+
+// NewStatusBarController initialises the StatusBarController
+func NewStatusBarController(lastPlayer *LastPlayer) *StatusBarController {
+	s := &StatusBarController{
+		lastPlayer: lastPlayer,
+		logger:     lastPlayer.GetLogger("StatusBarController"),
+	}
+	lastPlayer.Views.MessageHistory.SetInputCapture(s.InputHandler)
+	return s
+}
+
+// Push queues a message to be shown in the status bar, it is cleared after messageTTL
+// unless it has been replaced by a newer message. It is safe to call from any goroutine.
+func (s *StatusBarController) Push(level Level, text string) {
+	message := Message{Level: level, Text: text, Time: time.Now()}
+	s.logger.Printf("%s: %s", level, text)
+
+	go s.lastPlayer.QueueUpdateDraw(func() {
+		s.history = append(s.history, message)
+		if len(s.history) > historyLength {
+			s.history = s.history[len(s.history)-historyLength:]
+		}
+		s.shown++
+		shown := s.shown
+		s.lastPlayer.Views.StatusBar.SetText(message.render())
+		s.renderHistory()
+
+		time.AfterFunc(messageTTL, func() {
+			s.lastPlayer.QueueUpdateDraw(func() {
+				if s.shown == shown {
+					s.lastPlayer.Views.StatusBar.Clear()
+				}
+			})
+		})
+	})
+}
+
+// Handlers implements the EventController interface, announcing feeds as they
+// are loaded and episodes as they start playing
+func (s *StatusBarController) Handlers() map[domain.Topic]domain.Handler {
+	return map[domain.Topic]domain.Handler{
+		domain.FeedRefreshedTopic: func(event domain.Event) {
+			channel := event.(domain.FeedRefreshed).Feed.Channel[0]
+			s.Push(InfoLevel, fmt.Sprintf("Loaded %s, %d episodes", channel.Title, len(channel.Item)))
+		},
+		domain.EpisodeStartedTopic: func(event domain.Event) {
+			s.Push(InfoLevel, "Playing "+event.(domain.EpisodeStarted).Episode.Title)
+		},
+	}
+}
+
+// renderHistory writes the message history into the popup, newest last
+func (s *StatusBarController) renderHistory() {
+	view := s.lastPlayer.Views.MessageHistory
+	view.Clear()
+	for _, message := range s.history {
+		_, _ = fmt.Fprintln(view, message.render())
+	}
+	view.ScrollToEnd()
+}
+
+// ToggleHistory shows the message history popup, or hides it if it is already showing
+func (s *StatusBarController) ToggleHistory() {
+	pages := s.lastPlayer.Views.Pages
+	if name, _ := pages.GetFrontPage(); name == messagesPage {
+		pages.HidePage(messagesPage)
+		if s.prevFocus != nil {
+			s.lastPlayer.SetFocus(s.prevFocus)
+		}
+		return
+	}
+
+	s.prevFocus = s.lastPlayer.GetFocus()
+	pages.ShowPage(messagesPage)
+	s.lastPlayer.SetFocus(s.lastPlayer.Views.MessageHistory)
+}
+
+// InputHandler closes the message history popup, any other keys are left to scroll it
+func (s *StatusBarController) InputHandler(event *tcell.EventKey) *tcell.EventKey {
+	if ShowMessages(event) || Back(event) {
+		s.ToggleHistory()
+		return nil
+	}
+	return event
+}
@@ -0,0 +1,319 @@
+package view
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/wombatlord/last-player-on-the-left/src/app"
+	"os"
+	"strings"
+	"time"
+)
+
+// Palette holds the colors the views are drawn with. A Selection of tcell.ColorDefault
+// marks the selected item by reversing its colors instead. Contrast is the background of
+// popups and Inverse the text drawn on the other colors, Muted, Warning
+// and Error color the status bar. None of these are configurable.
+type Palette struct {
+	Background    tcell.Color
+	Contrast      tcell.Color
+	Inverse       tcell.Color
+	Text          tcell.Color
+	Secondary     tcell.Color
+	Border        tcell.Color
+	Title         tcell.Color
+	Selection     tcell.Color
+	SelectedText  tcell.Color
+	Played        tcell.Color
+	Unplayed      tcell.Color
+	Progress      tcell.Color
+	ProgressTrack tcell.Color
+	Muted         tcell.Color
+	Warning       tcell.Color
+	Error         tcell.Color
+}
+
+// DefaultTheme is the theme used when none is configured
+const DefaultTheme = "default"
+
+// Themes are the built in themes that can be selected with the theme setting
+var Themes = map[string]Palette{
+	// default takes tview's own colors for the styles the palette names. The others, the
+	// more contrasting background and tertiary text that tview colors green and contrast
+	// secondary text that it colors dark blue, follow Contrast and Secondary instead.
+	DefaultTheme: {
+		Background:    tcell.ColorBlack,
+		Contrast:      tcell.ColorBlue,
+		Inverse:       tcell.ColorBlue,
+		Text:          tcell.ColorWhite,
+		Secondary:     tcell.ColorYellow,
+		Border:        tcell.ColorWhite,
+		Title:         tcell.ColorWhite,
+		Selection:     tcell.ColorWhite,
+		SelectedText:  tcell.ColorBlack,
+		Played:        tcell.ColorGray,
+		Unplayed:      tcell.ColorWhite,
+		Progress:      tcell.ColorGreen,
+		ProgressTrack: tcell.ColorGray,
+		Muted:         tcell.ColorGray,
+		Warning:       tcell.ColorYellow,
+		Error:         tcell.ColorRed,
+	},
+	// light is readable on terminals with a light background
+	"light": {
+		Background:    tcell.ColorDefault,
+		Contrast:      tcell.ColorDefault,
+		Inverse:       tcell.ColorDefault,
+		Text:          tcell.ColorBlack,
+		Secondary:     tcell.ColorTeal,
+		Border:        tcell.ColorGray,
+		Title:         tcell.ColorNavy,
+		Selection:     tcell.ColorNavy,
+		SelectedText:  tcell.ColorWhite,
+		Played:        tcell.ColorGray,
+		Unplayed:      tcell.ColorBlack,
+		Progress:      tcell.ColorBlue,
+		ProgressTrack: tcell.ColorSilver,
+		Muted:         tcell.ColorGray,
+		Warning:       tcell.ColorOlive,
+		Error:         tcell.ColorMaroon,
+	},
+	// terminal draws everything in the terminal's own foreground and background
+	"terminal": {
+		Background:    tcell.ColorDefault,
+		Contrast:      tcell.ColorDefault,
+		Inverse:       tcell.ColorDefault,
+		Text:          tcell.ColorDefault,
+		Secondary:     tcell.ColorDefault,
+		Border:        tcell.ColorDefault,
+		Title:         tcell.ColorDefault,
+		Selection:     tcell.ColorDefault,
+		SelectedText:  tcell.ColorDefault,
+		Played:        tcell.ColorGray,
+		Unplayed:      tcell.ColorDefault,
+		Progress:      tcell.ColorGreen,
+		ProgressTrack: tcell.ColorGray,
+		Muted:         tcell.ColorGray,
+		Warning:       tcell.ColorYellow,
+		Error:         tcell.ColorRed,
+	},
+}
+
+// noColor is the palette used when the NO_COLOR environment variable is set, see
+// https://no-color.org
+var noColor = Palette{
+	Background:    tcell.ColorDefault,
+	Contrast:      tcell.ColorDefault,
+	Inverse:       tcell.ColorDefault,
+	Text:          tcell.ColorDefault,
+	Secondary:     tcell.ColorDefault,
+	Border:        tcell.ColorDefault,
+	Title:         tcell.ColorDefault,
+	Selection:     tcell.ColorDefault,
+	SelectedText:  tcell.ColorDefault,
+	Played:        tcell.ColorDefault,
+	Unplayed:      tcell.ColorDefault,
+	Progress:      tcell.ColorDefault,
+	ProgressTrack: tcell.ColorDefault,
+	Muted:         tcell.ColorDefault,
+	Warning:       tcell.ColorDefault,
+	Error:         tcell.ColorDefault,
+}
+
+// palette holds the colors the views are currently drawn with
+var palette = Themes[DefaultTheme]
+
+// NewPalette resolves the configured theme to a Palette, starting from the named theme
+// and overriding any colors that are set. NO_COLOR takes precedence over the theme.
+func NewPalette(theme app.Theme) (Palette, error) {
+	if os.Getenv("NO_COLOR") != "" {
+		return noColor, nil
+	}
+
+	name := theme.Name
+	if name == "" {
+		name = DefaultTheme
+	}
+	p, ok := Themes[name]
+	if !ok {
+		return Palette{}, fmt.Errorf("theme: unknown theme %q", name)
+	}
+
+	overrides := []struct {
+		name  string
+		spec  string
+		color *tcell.Color
+	}{
+		{"background", theme.Background, &p.Background},
+		{"text", theme.Text, &p.Text},
+		{"secondary", theme.Secondary, &p.Secondary},
+		{"border", theme.Border, &p.Border},
+		{"title", theme.Title, &p.Title},
+		{"selection", theme.Selection, &p.Selection},
+		{"selected_text", theme.SelectedText, &p.SelectedText},
+		{"played", theme.Played, &p.Played},
+		{"unplayed", theme.Unplayed, &p.Unplayed},
+		{"progress", theme.Progress, &p.Progress},
+		{"progress_track", theme.ProgressTrack, &p.ProgressTrack},
+	}
+	for _, override := range overrides {
+		if override.spec == "" {
+			continue
+		}
+		color, err := parseColor(override.spec)
+		if err != nil {
+			return Palette{}, fmt.Errorf("theme: %s: %v", override.name, err)
+		}
+		*override.color = color
+	}
+	return p, nil
+}
+
+// parseColor parses a color name, a hex value or default, the terminal's own color
+func parseColor(spec string) (tcell.Color, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "default" {
+		return tcell.ColorDefault, nil
+	}
+	if _, named := tcell.ColorNames[spec]; !named && !strings.HasPrefix(spec, "#") {
+		return tcell.ColorDefault, fmt.Errorf("unknown color %q", spec)
+	}
+	color := tcell.GetColor(spec)
+	if color == tcell.ColorDefault {
+		return tcell.ColorDefault, fmt.Errorf("unknown color %q", spec)
+	}
+	return color, nil
+}
+
+// tag returns the color as a tview color tag
+func tag(color tcell.Color) string {
+	if color == tcell.ColorDefault {
+		return "[-]"
+	}
+	return fmt.Sprintf("[#%06x]", color.Hex())
+}
+
+// selectedStyle returns the style that selected items are drawn with
+func (p Palette) selectedStyle() tcell.Style {
+	if p.Selection == tcell.ColorDefault {
+		return tcell.StyleDefault.Reverse(true)
+	}
+	return tcell.StyleDefault.Background(p.Selection).Foreground(p.SelectedText)
+}
+
+// setStyles makes the palette the default for primitives created from now on
+func (p Palette) setStyles() {
+	palette = p
+	tview.Styles.PrimitiveBackgroundColor = p.Background
+	tview.Styles.ContrastBackgroundColor = p.Contrast
+	tview.Styles.MoreContrastBackgroundColor = p.Contrast
+	tview.Styles.BorderColor = p.Border
+	tview.Styles.TitleColor = p.Title
+	tview.Styles.GraphicsColor = p.Border
+	tview.Styles.PrimaryTextColor = p.Text
+	tview.Styles.SecondaryTextColor = p.Secondary
+	tview.Styles.TertiaryTextColor = p.Secondary
+	tview.Styles.InverseTextColor = p.Inverse
+	tview.Styles.ContrastSecondaryTextColor = p.Secondary
+}
+
+// applyTheme redraws the views that have already been created with the palette
+func (lp *LastPlayer) applyTheme(p Palette) {
+	p.setStyles()
+	v := lp.Views
+
+	boxes := []*tview.Box{
+		v.FeedMenu.Box, v.EpisodeMenu.Box, v.EpisodeFilter.Box, v.Details.Box, v.Player.Box, v.APView.Box, v.Progress.Box,
+		v.StatusBar.Box, v.MessageHistory.Box, v.Search.Box, v.SearchInput.Box,
+		v.SearchResults.Box, v.CommandLine.Box, v.Help.Box,
+	}
+	for _, box := range boxes {
+		box.SetBackgroundColor(p.Background).
+			SetBorderColor(p.Border).
+			SetTitleColor(p.Title)
+	}
+
+	for _, list := range []*tview.List{v.FeedMenu, v.EpisodeMenu} {
+		list.SetMainTextColor(p.Text).
+			SetSecondaryTextColor(p.Secondary).
+			SetShortcutColor(p.Secondary).
+			SetSelectedStyle(p.selectedStyle())
+	}
+	for _, text := range []*tview.TextView{v.Details, v.APView, v.StatusBar, v.MessageHistory, v.Help} {
+		text.SetTextColor(p.Text)
+	}
+	for _, input := range []*tview.InputField{v.EpisodeFilter, v.SearchInput, v.CommandLine} {
+		input.SetLabelColor(p.Secondary).
+			SetFieldTextColor(p.Text).
+			SetFieldBackgroundColor(p.Background)
+	}
+	v.SearchResults.SetSelectedStyle(p.selectedStyle())
+	v.ErrorModal.SetBackgroundColor(p.Contrast).
+		SetTextColor(p.Text).
+		SetButtonBackgroundColor(p.Selection).
+		SetButtonTextColor(p.SelectedText)
+
+	// Views that color their content themselves are rendered again
+	lp.Controllers.EpisodeMenu.refresh()
+	lp.Controllers.StatusBar.renderHistory()
+	lp.Controllers.Details.render()
+	lp.Controllers.APViewController.RenderState(lp.AudioPanel.GetPlayerState())
+}
+
+// themeInterval is how often the config file is checked for a changed theme
+const themeInterval = time.Second
+
+// watchTheme checks the config file for changes to the theme until done is closed, and
+// applies a changed theme straight away
+func (lp *LastPlayer) watchTheme(path string, done <-chan struct{}) {
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+	current := lp.Config.Theme
+
+	ticker := time.NewTicker(themeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(modified) {
+			continue
+		}
+		modified = info.ModTime()
+
+		config, err := app.ReadConfig(path)
+		if err != nil {
+			lp.logger.Printf("Could not reload the config: %v", err)
+			continue
+		}
+		if config.Theme == current {
+			continue
+		}
+		current = config.Theme
+
+		p, err := NewPalette(config.Theme)
+		if err != nil {
+			lp.Warn("%v", err)
+			continue
+		}
+		lp.QueueUpdateDraw(func() {
+			lp.Config.Theme = config.Theme
+			lp.applyTheme(p)
+		})
+		lp.Info("Theme changed to %s", themeName(config.Theme))
+	}
+}
+
+// themeName returns the name of the configured theme
+func themeName(theme app.Theme) string {
+	if theme.Name == "" {
+		return DefaultTheme
+	}
+	return theme.Name
+}